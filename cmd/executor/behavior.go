@@ -0,0 +1,130 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// The ways the executor can answer an order
+const (
+	// actionFill fills the order, by default at once and in full
+	actionFill = "fill"
+
+	// actionAck acknowledges the order and leaves it open
+	actionAck = "ack"
+)
+
+// behavior describes how the executor answers the orders it applies to
+type behavior struct {
+	// Symbol and Account select the orders the behavior applies to, empty matches any
+	Symbol  string `yaml:"symbol"`
+	Account string `yaml:"account"`
+
+	Action string `yaml:"action"`
+
+	// Slices is the number of fills the order quantity is split into, Interval the pause
+	// between them
+	Slices   int           `yaml:"slices"`
+	Interval time.Duration `yaml:"interval"`
+
+	// Delay is how long the first fill comes after the order is acknowledged
+	Delay time.Duration `yaml:"delay"`
+
+	// PriceOffset moves the price of every fill in favor of the order, up for a sell and
+	// down for a buy, a negative offset only as far as the limit price
+	PriceOffset decimal.Decimal `yaml:"priceOffset"`
+
+	// RejectRate is the chance, from 0 to 1, that an order is rejected with RejectText
+	RejectRate float64 `yaml:"rejectRate"`
+	RejectText string  `yaml:"rejectText"`
+}
+
+// isImmediate returns true if the order is filled in full as soon as it arrives, without
+// being acknowledged first
+func (b behavior) isImmediate() bool {
+	return b.Action == actionFill && b.Slices <= 1 && b.Delay == 0
+}
+
+// behaviors are the rules loaded from a behaviors file
+type behaviors struct {
+	// Default applies to orders no rule matches
+	Default behavior   `yaml:"default"`
+	Rules   []behavior `yaml:"rules"`
+}
+
+// defaultBehaviors fills every order at once, as the executor does without a behaviors file
+func defaultBehaviors() *behaviors {
+	return &behaviors{Default: behavior{Action: actionFill}}
+}
+
+// loadBehaviors reads the behaviors file at fileName
+func loadBehaviors(fileName string) (*behaviors, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b := defaultBehaviors()
+	if err := yaml.NewDecoder(file).Decode(b); err != nil {
+		return nil, fmt.Errorf("error reading behaviors %v: %s", fileName, err)
+	}
+
+	for i := range b.Rules {
+		if err := b.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("error reading behaviors %v: rule %v: %s", fileName, i+1, err)
+		}
+	}
+
+	if err := b.Default.validate(); err != nil {
+		return nil, fmt.Errorf("error reading behaviors %v: default: %s", fileName, err)
+	}
+
+	return b, nil
+}
+
+func (b *behavior) validate() error {
+	switch b.Action {
+	case "":
+		b.Action = actionFill
+	case actionFill, actionAck:
+	default:
+		return fmt.Errorf("unknown action %q, expected %v or %v", b.Action, actionFill, actionAck)
+	}
+
+	if b.RejectRate < 0 || b.RejectRate > 1 {
+		return fmt.Errorf("rejectRate %v is not between 0 and 1", b.RejectRate)
+	}
+
+	return nil
+}
+
+// match returns the first rule for symbol and account, the default behavior if there is none
+func (b behaviors) match(symbol, account string) behavior {
+	for _, rule := range b.Rules {
+		if (rule.Symbol == "" || rule.Symbol == symbol) && (rule.Account == "" || rule.Account == account) {
+			return rule
+		}
+	}
+
+	return b.Default
+}
@@ -0,0 +1,107 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+func TestBehaviorsMatch(t *testing.T) {
+	b := behaviors{
+		Default: behavior{Action: actionFill},
+		Rules: []behavior{
+			{Symbol: "ACK", Account: "SLOW", Action: actionAck, Slices: 2},
+			{Symbol: "ACK", Action: actionAck},
+			{Account: "TEST", RejectRate: 1},
+		},
+	}
+
+	tests := []struct {
+		symbol, account string
+		want            behavior
+	}{
+		{"ACK", "SLOW", b.Rules[0]},
+		{"ACK", "OTHER", b.Rules[1]},
+		{"ACK", "TEST", b.Rules[1]},
+		{"ABC", "TEST", b.Rules[2]},
+		{"ABC", "", b.Default},
+	}
+
+	for _, test := range tests {
+		if got := b.match(test.symbol, test.account); got != test.want {
+			t.Errorf("match %v %v: got %+v, want %+v", test.symbol, test.account, got, test.want)
+		}
+	}
+}
+
+func TestLoadBehaviors(t *testing.T) {
+	if _, err := loadBehaviors(filepath.Join("..", "..", "config", "executor_behaviors.yaml")); err != nil {
+		t.Errorf("example behaviors: %v", err)
+	}
+
+	tests := []struct {
+		name, yaml string
+	}{
+		{"unknown action", "rules:\n  - symbol: ABC\n    action: cancel\n"},
+		{"reject rate above 1", "rules:\n  - symbol: ABC\n    rejectRate: 2\n"},
+		{"default reject rate below 0", "default:\n  rejectRate: -1\n"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "behaviors.yaml")
+		if err := os.WriteFile(path, []byte(test.yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadBehaviors(path); err == nil {
+			t.Errorf("%v: loaded without an error", test.name)
+		}
+	}
+}
+
+func TestFillPrice(t *testing.T) {
+	e := newExecutor(defaultBehaviors(), newPriceFeed(), nil)
+	e.prices.Reference["ABC"] = decimal.NewFromInt(20)
+
+	tests := []struct {
+		side    enum.Side
+		ordType enum.OrdType
+		offset  string
+		want    string
+	}{
+		{enum.Side_BUY, enum.OrdType_LIMIT, "0", "10"},
+		{enum.Side_BUY, enum.OrdType_LIMIT, "0.01", "9.99"},
+		{enum.Side_SELL, enum.OrdType_LIMIT, "0.01", "10.01"},
+		// a negative offset never fills through the limit
+		{enum.Side_BUY, enum.OrdType_LIMIT, "-0.01", "10"},
+		{enum.Side_SELL, enum.OrdType_LIMIT, "-0.01", "10"},
+		{enum.Side_BUY, enum.OrdType_MARKET, "0.01", "19.99"},
+		{enum.Side_SELL, enum.OrdType_MARKET, "0.01", "20.01"},
+		{enum.Side_BUY, enum.OrdType_MARKET, "-0.01", "20.01"},
+	}
+
+	for _, test := range tests {
+		o := &order{symbol: "ABC", side: test.side, ordType: test.ordType, price: decimal.NewFromInt(10)}
+		b := behavior{Action: actionFill, PriceOffset: decimal.RequireFromString(test.offset)}
+		if got := e.fillPrice(o, b); !got.Equal(decimal.RequireFromString(test.want)) {
+			t.Errorf("%v %v offset %v: got %v, want %v", test.side, test.ordType, test.offset, got, test.want)
+		}
+	}
+}
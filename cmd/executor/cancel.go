@@ -0,0 +1,212 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"errors"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
+	"github.com/quickfixgo/examples/cmd/utils"
+	"github.com/shopspring/decimal"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+var (
+	// errUnknownOrder is returned for a request naming no open order of the session
+	errUnknownOrder = errors.New("unknown order")
+
+	// errTooLateToCancel is returned for a request naming an order that has been filled or canceled
+	errTooLateToCancel = errors.New("too late to cancel")
+
+	// errDuplicateClOrdID is returned for an order or replace reusing the ClOrdID of an order of the session
+	errDuplicateClOrdID = errors.New("duplicate ClOrdID")
+
+	// errQuantityFilled is returned for a replace to no more than the filled quantity
+	errQuantityFilled = errors.New("order quantity not above the filled quantity")
+)
+
+// orderCancelRequest is the part of OrderCancelRequest common to every supported FIX version
+type orderCancelRequest interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetOrigClOrdID() (string, quickfix.MessageRejectError)
+}
+
+// orderCancelReplaceRequest is the part of OrderCancelReplaceRequest common to every supported FIX version
+type orderCancelReplaceRequest interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetOrigClOrdID() (string, quickfix.MessageRejectError)
+	HasPrice() bool
+	GetPrice() (decimal.Decimal, quickfix.MessageRejectError)
+	HasOrderQty() bool
+	GetOrderQty() (decimal.Decimal, quickfix.MessageRejectError)
+	HasStopPx() bool
+	GetStopPx() (decimal.Decimal, quickfix.MessageRejectError)
+}
+
+func (e *executor) onOrderCancelRequest(msg orderCancelRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	clOrdID, err := msg.GetClOrdID()
+	if err != nil {
+		return err
+	}
+
+	origClOrdID, err := msg.GetOrigClOrdID()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	req := messages.CancelRequest{ClOrdID: clOrdID, OrigClOrdID: origClOrdID, ResponseTo: enum.CxlRejResponseTo_ORDER_CANCEL_REQUEST}
+
+	key := orderKey{sessionID, origClOrdID}
+	o, ok := e.orders[key]
+	if !ok {
+		e.rejectCancelRequest(req, e.closed[key], e.notOpen(key), sessionID)
+		return nil
+	}
+
+	if e.known(orderKey{sessionID, clOrdID}) {
+		e.rejectCancelRequest(req, o, errDuplicateClOrdID, sessionID)
+		return nil
+	}
+
+	// the order is closed under both its ClOrdID and that of the cancel
+	o.canceled, o.closed = true, true
+	e.close(o)
+	o.clOrdID, o.origClOrdID = clOrdID, o.clOrdID
+	e.remember(o.key(), o)
+
+	e.send(o, execution{execType: enum.ExecType_CANCELED, ordStatus: enum.OrdStatus_CANCELED})
+
+	return nil
+}
+
+func (e *executor) onOrderCancelReplaceRequest(msg orderCancelReplaceRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	clOrdID, err := msg.GetClOrdID()
+	if err != nil {
+		return err
+	}
+
+	origClOrdID, err := msg.GetOrigClOrdID()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	req := messages.CancelRequest{ClOrdID: clOrdID, OrigClOrdID: origClOrdID, ResponseTo: enum.CxlRejResponseTo_ORDER_CANCEL_REPLACE_REQUEST}
+
+	key := orderKey{sessionID, origClOrdID}
+	o, ok := e.orders[key]
+	if !ok {
+		e.rejectCancelRequest(req, e.closed[key], e.notOpen(key), sessionID)
+		return nil
+	}
+
+	if e.known(orderKey{sessionID, clOrdID}) {
+		e.rejectCancelRequest(req, o, errDuplicateClOrdID, sessionID)
+		return nil
+	}
+
+	price := o.price
+	if msg.HasPrice() {
+		if price, err = msg.GetPrice(); err != nil {
+			return err
+		}
+	}
+
+	orderQty := o.quantity
+	if msg.HasOrderQty() {
+		if orderQty, err = msg.GetOrderQty(); err != nil {
+			return err
+		}
+	}
+
+	stopPx := o.stopPx
+	if msg.HasStopPx() {
+		if stopPx, err = msg.GetStopPx(); err != nil {
+			return err
+		}
+	}
+
+	if orderQty.LessThanOrEqual(o.cumQty) {
+		e.rejectCancelRequest(req, o, errQuantityFilled, sessionID)
+		return nil
+	}
+
+	replaced := *o
+	replaced.price, replaced.stopPx, replaced.quantity = price, stopPx, orderQty
+	if err := e.checkOrder(&replaced); err != nil {
+		e.rejectCancelRequest(req, o, err, sessionID)
+		return nil
+	}
+
+	// the original ClOrdID stays used, and too late to cancel
+	delete(e.orders, o.key())
+	e.remember(o.key(), o)
+	o.clOrdID, o.origClOrdID = clOrdID, o.clOrdID
+	o.price, o.stopPx, o.quantity = price, stopPx, orderQty
+	e.orders[o.key()] = o
+
+	// FIX 4.3 and later report the status of the order after the replace where earlier
+	// versions report REPLACED
+	status := o.status()
+	switch sessionID.BeginString {
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41, quickfix.BeginStringFIX42:
+		status = enum.OrdStatus_REPLACED
+	}
+	e.send(o, execution{execType: enum.ExecType_REPLACED, ordStatus: status})
+
+	// a stop order may now be crossed by the current price
+	if last, ok := e.prices.price(o.symbol); ok && o.isStop() && !o.triggered && o.crosses(last) {
+		e.trigger(o)
+	}
+
+	return nil
+}
+
+// rejectCancelRequest answers req with an OrderCancelReject in the FIX version of sessionID.
+// o is the order the request referred to, nil if there is no such order. The ClOrdID of req is
+// used up all the same.
+func (e *executor) rejectCancelRequest(req messages.CancelRequest, o *order, reason error, sessionID quickfix.SessionID) {
+	if key := (orderKey{sessionID, req.ClOrdID}); !e.known(key) {
+		e.remember(key, o)
+	}
+
+	orderID, status := "NONE", enum.OrdStatus_REJECTED
+	if o != nil {
+		orderID, status = o.orderID, o.status()
+	}
+
+	cxlRejReason := enum.CxlRejReason_BROKER
+	switch reason {
+	case errUnknownOrder:
+		cxlRejReason = enum.CxlRejReason_UNKNOWN_ORDER
+	case errTooLateToCancel:
+		cxlRejReason = enum.CxlRejReason_TOO_LATE_TO_CANCEL
+	case errDuplicateClOrdID:
+		cxlRejReason = enum.CxlRejReason_DUPLICATE_CLORDID
+	}
+
+	msg := messages.NewOrderCancelReject(req, orderID, status, cxlRejReason, reason.Error(), sessionID.BeginString)
+	if err := quickfix.SendToTarget(msg, sessionID); err != nil {
+		utils.PrintBad(err.Error())
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+// testSession is the session the executor tests trade on, no such session exists so nothing
+// is sent
+var testSession = quickfix.SessionID{BeginString: quickfix.BeginStringFIX44, SenderCompID: "ISLD", TargetCompID: "TW"}
+
+// testCancel is an OrderCancelRequest
+type testCancel struct {
+	clOrdID, origClOrdID string
+}
+
+func (c testCancel) GetClOrdID() (string, quickfix.MessageRejectError)     { return c.clOrdID, nil }
+func (c testCancel) GetOrigClOrdID() (string, quickfix.MessageRejectError) { return c.origClOrdID, nil }
+
+// testReplace is an OrderCancelReplaceRequest changing the quantity
+type testReplace struct {
+	testCancel
+	quantity int64
+}
+
+func (r testReplace) HasPrice() bool { return false }
+func (r testReplace) GetPrice() (decimal.Decimal, quickfix.MessageRejectError) {
+	return decimal.Zero, nil
+}
+func (r testReplace) HasOrderQty() bool { return true }
+func (r testReplace) GetOrderQty() (decimal.Decimal, quickfix.MessageRejectError) {
+	return decimal.NewFromInt(r.quantity), nil
+}
+func (r testReplace) HasStopPx() bool { return false }
+func (r testReplace) GetStopPx() (decimal.Decimal, quickfix.MessageRejectError) {
+	return decimal.Zero, nil
+}
+
+// newTestExecutor returns an executor with an open order 1 for 100 ABC
+func newTestExecutor() *executor {
+	e := newExecutor(defaultBehaviors(), newPriceFeed(), nil)
+	o := &order{
+		sessionID: testSession,
+		orderID:   "1",
+		clOrdID:   "1",
+		symbol:    "ABC",
+		side:      enum.Side_BUY,
+		ordType:   enum.OrdType_LIMIT,
+		price:     decimal.NewFromInt(10),
+		quantity:  decimal.NewFromInt(100),
+	}
+	e.orders[o.key()] = o
+
+	return e
+}
+
+func TestClOrdIDBookkeeping(t *testing.T) {
+	// open, closed and refused list the ClOrdIDs that must be open, closed, and used by a
+	// refused request after the requests
+	tests := []struct {
+		name     string
+		requests []any
+		open     []string
+		closed   []string
+		refused  []string
+	}{
+		{
+			name:     "replace",
+			requests: []any{testReplace{testCancel{"2", "1"}, 200}},
+			open:     []string{"2"},
+			closed:   []string{"1"},
+		},
+		{
+			name:     "replace twice",
+			requests: []any{testReplace{testCancel{"2", "1"}, 200}, testReplace{testCancel{"3", "2"}, 300}},
+			open:     []string{"3"},
+			closed:   []string{"1", "2"},
+		},
+		{
+			name:     "cancel",
+			requests: []any{testCancel{"2", "1"}},
+			closed:   []string{"1", "2"},
+		},
+		{
+			name:     "cancel reusing the open ClOrdID",
+			requests: []any{testCancel{"1", "1"}},
+			open:     []string{"1"},
+		},
+		{
+			name:     "cancel reusing a replaced ClOrdID",
+			requests: []any{testReplace{testCancel{"2", "1"}, 200}, testCancel{"1", "2"}},
+			open:     []string{"2"},
+			closed:   []string{"1"},
+		},
+		{
+			name:     "cancel of an unknown order",
+			requests: []any{testCancel{"2", "9"}},
+			open:     []string{"1"},
+			refused:  []string{"2"},
+		},
+		{
+			name:     "replace below the filled quantity",
+			requests: []any{testReplace{testCancel{"2", "1"}, 0}},
+			open:     []string{"1"},
+			refused:  []string{"2"},
+		},
+	}
+
+	for _, test := range tests {
+		e := newTestExecutor()
+		for _, req := range test.requests {
+			switch req := req.(type) {
+			case testReplace:
+				e.onOrderCancelReplaceRequest(req, testSession)
+			case testCancel:
+				e.onOrderCancelRequest(req, testSession)
+			}
+		}
+
+		for _, clOrdID := range test.open {
+			if _, ok := e.orders[orderKey{testSession, clOrdID}]; !ok {
+				t.Errorf("%v: %v is not open", test.name, clOrdID)
+			}
+		}
+		for _, clOrdID := range test.closed {
+			key := orderKey{testSession, clOrdID}
+			if _, ok := e.orders[key]; ok {
+				t.Errorf("%v: %v is open", test.name, clOrdID)
+			}
+			if !e.known(key) {
+				t.Errorf("%v: %v may be used again", test.name, clOrdID)
+			}
+			if err := e.notOpen(key); err != errTooLateToCancel {
+				t.Errorf("%v: cancel of %v got %v, want %v", test.name, clOrdID, err, errTooLateToCancel)
+			}
+		}
+		for _, clOrdID := range test.refused {
+			key := orderKey{testSession, clOrdID}
+			if _, ok := e.orders[key]; ok {
+				t.Errorf("%v: %v is open", test.name, clOrdID)
+			}
+			if !e.known(key) {
+				t.Errorf("%v: %v may be used again", test.name, clOrdID)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/shopspring/decimal"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix40er "github.com/quickfixgo/fix40/executionreport"
+	fix41er "github.com/quickfixgo/fix41/executionreport"
+	fix42er "github.com/quickfixgo/fix42/executionreport"
+	fix43er "github.com/quickfixgo/fix43/executionreport"
+	fix44er "github.com/quickfixgo/fix44/executionreport"
+	fix50er "github.com/quickfixgo/fix50/executionreport"
+)
+
+// execution is what an execution report tells about its order
+type execution struct {
+	execType  enum.ExecType
+	ordStatus enum.OrdStatus
+	lastQty   decimal.Decimal
+	lastPx    decimal.Decimal
+	text      string
+
+	// ordRejReason is why a rejected order was refused
+	ordRejReason enum.OrdRejReason
+}
+
+// newExecutionReport builds the execution report for o in the FIX version of its session
+func (e *executor) newExecutionReport(o *order, x execution) *quickfix.Message {
+	orderID := field.NewOrderID(o.orderID)
+	execID := e.genExecID()
+	execType := field.NewExecType(x.execType)
+	ordStatus := field.NewOrdStatus(x.ordStatus)
+	symbol := field.NewSymbol(o.symbol)
+	side := field.NewSide(o.side)
+	orderQty := field.NewOrderQty(o.quantity, 2)
+	leavesQty := field.NewLeavesQty(o.leavesQty(), 2)
+	cumQty := field.NewCumQty(o.cumQty, 2)
+	avgPx := field.NewAvgPx(o.avgPx(), 2)
+
+	// FIX 4.4 and later report every fill as a TRADE
+	isFill := x.execType == enum.ExecType_FILL || x.execType == enum.ExecType_PARTIAL_FILL
+	switch o.sessionID.BeginString {
+	case quickfix.BeginStringFIX44, quickfix.BeginStringFIXT11:
+		if isFill {
+			execType = field.NewExecType(enum.ExecType_TRADE)
+		}
+	}
+
+	var msg *quickfix.Message
+	switch o.sessionID.BeginString {
+	case quickfix.BeginStringFIX40:
+		msg = fix40er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), ordStatus, symbol, side, orderQty,
+			field.NewLastShares(x.lastQty, 2),
+			field.NewLastPx(x.lastPx, 2),
+			cumQty, avgPx,
+		).ToMessage()
+
+	case quickfix.BeginStringFIX41:
+		msg = fix41er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), execType, ordStatus, symbol, side, orderQty,
+			field.NewLastShares(x.lastQty, 2),
+			field.NewLastPx(x.lastPx, 2),
+			leavesQty, cumQty, avgPx,
+		).ToMessage()
+
+	case quickfix.BeginStringFIX42:
+		msg = fix42er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), execType, ordStatus, symbol, side, leavesQty, cumQty, avgPx).ToMessage()
+		msg.Body.Set(field.NewLastShares(x.lastQty, 2))
+		msg.Body.Set(field.NewLastPx(x.lastPx, 2))
+
+	case quickfix.BeginStringFIX43:
+		msg = fix43er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty, avgPx).ToMessage()
+		msg.Body.Set(field.NewLastQty(x.lastQty, 2))
+		msg.Body.Set(field.NewLastPx(x.lastPx, 2))
+
+	case quickfix.BeginStringFIX44:
+		msg = fix44er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty, avgPx).ToMessage()
+		msg.Body.Set(field.NewLastQty(x.lastQty, 2))
+		msg.Body.Set(field.NewLastPx(x.lastPx, 2))
+
+	default:
+		msg = fix50er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty).ToMessage()
+		msg.Body.Set(avgPx)
+		msg.Body.Set(field.NewLastQty(x.lastQty, 2))
+		msg.Body.Set(field.NewLastPx(x.lastPx, 2))
+	}
+
+	// every version echoes the same tags of the order
+	msg.Body.Set(field.NewClOrdID(o.clOrdID))
+	msg.Body.Set(symbol)
+	msg.Body.Set(orderQty)
+	if o.account != "" {
+		msg.Body.Set(field.NewAccount(o.account))
+	}
+
+	// FIX 4.0 execution reports have no OrigClOrdID
+	if o.origClOrdID != "" && o.sessionID.BeginString != quickfix.BeginStringFIX40 {
+		msg.Body.Set(field.NewOrigClOrdID(o.origClOrdID))
+	}
+
+	if x.text != "" {
+		msg.Body.Set(field.NewText(x.text))
+	}
+	if x.ordStatus == enum.OrdStatus_REJECTED {
+		msg.Body.Set(field.NewOrdRejReason(x.ordRejReason))
+	}
+
+	return msg
+}
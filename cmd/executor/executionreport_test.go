@@ -0,0 +1,66 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+func TestExecutionReportExecType(t *testing.T) {
+	tests := []struct {
+		beginString string
+		execType    enum.ExecType
+		want        enum.ExecType
+	}{
+		{quickfix.BeginStringFIX41, enum.ExecType_PARTIAL_FILL, enum.ExecType_PARTIAL_FILL},
+		{quickfix.BeginStringFIX42, enum.ExecType_FILL, enum.ExecType_FILL},
+		{quickfix.BeginStringFIX43, enum.ExecType_PARTIAL_FILL, enum.ExecType_PARTIAL_FILL},
+		{quickfix.BeginStringFIX44, enum.ExecType_PARTIAL_FILL, enum.ExecType_TRADE},
+		{quickfix.BeginStringFIX44, enum.ExecType_FILL, enum.ExecType_TRADE},
+		{quickfix.BeginStringFIX44, enum.ExecType_CANCELED, enum.ExecType_CANCELED},
+		{quickfix.BeginStringFIXT11, enum.ExecType_FILL, enum.ExecType_TRADE},
+		{quickfix.BeginStringFIXT11, enum.ExecType_NEW, enum.ExecType_NEW},
+	}
+
+	e := newExecutor(defaultBehaviors(), newPriceFeed(), nil)
+	for _, test := range tests {
+		o := &order{
+			sessionID: quickfix.SessionID{BeginString: test.beginString},
+			orderID:   "1",
+			clOrdID:   "1",
+			symbol:    "ABC",
+			side:      enum.Side_BUY,
+			ordType:   enum.OrdType_LIMIT,
+			price:     decimal.NewFromInt(10),
+			quantity:  decimal.NewFromInt(100),
+		}
+
+		msg := e.newExecutionReport(o, execution{execType: test.execType, ordStatus: enum.OrdStatus_NEW})
+
+		var execType field.ExecTypeField
+		if err := msg.Body.Get(&execType); err != nil {
+			t.Fatalf("%v: %v", test.beginString, err)
+		}
+		if execType.Value() != test.want {
+			t.Errorf("%v %v: ExecType %v, want %v", test.beginString, test.execType, execType.Value(), test.want)
+		}
+	}
+}
@@ -17,12 +17,18 @@ package executor
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"path"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/instruments"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
 	"github.com/quickfixgo/examples/cmd/utils"
 	"github.com/quickfixgo/field"
 	"github.com/quickfixgo/tag"
@@ -38,32 +44,98 @@ import (
 	fix44nos "github.com/quickfixgo/fix44/newordersingle"
 	fix50nos "github.com/quickfixgo/fix50/newordersingle"
 
-	fix40er "github.com/quickfixgo/fix40/executionreport"
-	fix41er "github.com/quickfixgo/fix41/executionreport"
-	fix42er "github.com/quickfixgo/fix42/executionreport"
-	fix43er "github.com/quickfixgo/fix43/executionreport"
-	fix44er "github.com/quickfixgo/fix44/executionreport"
-	fix50er "github.com/quickfixgo/fix50/executionreport"
+	fix40cxl "github.com/quickfixgo/fix40/ordercancelrequest"
+	fix41cxl "github.com/quickfixgo/fix41/ordercancelrequest"
+	fix42cxl "github.com/quickfixgo/fix42/ordercancelrequest"
+	fix43cxl "github.com/quickfixgo/fix43/ordercancelrequest"
+	fix44cxl "github.com/quickfixgo/fix44/ordercancelrequest"
+	fix50cxl "github.com/quickfixgo/fix50/ordercancelrequest"
+
+	fix40ocrr "github.com/quickfixgo/fix40/ordercancelreplacerequest"
+	fix41ocrr "github.com/quickfixgo/fix41/ordercancelreplacerequest"
+	fix42ocrr "github.com/quickfixgo/fix42/ordercancelreplacerequest"
+	fix43ocrr "github.com/quickfixgo/fix43/ordercancelreplacerequest"
+	fix44ocrr "github.com/quickfixgo/fix44/ordercancelreplacerequest"
+	fix50ocrr "github.com/quickfixgo/fix50/ordercancelreplacerequest"
 
 	"os"
 	"os/signal"
 	"strconv"
 )
 
+// newOrderSingle is the part of NewOrderSingle common to every supported FIX version
+type newOrderSingle interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetSymbol() (string, quickfix.MessageRejectError)
+	GetSide() (enum.Side, quickfix.MessageRejectError)
+	GetOrdType() (enum.OrdType, quickfix.MessageRejectError)
+	GetOrderQty() (decimal.Decimal, quickfix.MessageRejectError)
+	GetPrice() (decimal.Decimal, quickfix.MessageRejectError)
+	GetStopPx() (decimal.Decimal, quickfix.MessageRejectError)
+	HasAccount() bool
+	GetAccount() (string, quickfix.MessageRejectError)
+}
+
+// closedOrdersKept is how many filled or canceled orders the executor remembers, the oldest
+// are forgotten first
+const closedOrdersKept = 100000
+
 type executor struct {
+	// mu guards the id counters and the orders
+	mu sync.Mutex
+
 	orderID int
 	execID  int
+	orders  map[orderKey]*order
+
+	// closed holds the orders that have been filled or canceled by the key they were closed
+	// under, replaced orders by the key they had before, and refused orders and requests by
+	// their own key, nil for a request that referred to no order, until closedOrdersKept newer
+	// ones have closed
+	closed     map[orderKey]*order
+	closedKeys []orderKey
+
+	behaviors *behaviors
+	prices    *priceFeed
+
+	// instruments lists the symbols orders are accepted in, any symbol when it is nil
+	instruments *instruments.Master
 	*quickfix.MessageRouter
 }
 
-func newExecutor() *executor {
-	e := &executor{MessageRouter: quickfix.NewMessageRouter()}
-	e.AddRoute(fix40nos.Route(e.OnFIX40NewOrderSingle))
-	e.AddRoute(fix41nos.Route(e.OnFIX41NewOrderSingle))
-	e.AddRoute(fix42nos.Route(e.OnFIX42NewOrderSingle))
-	e.AddRoute(fix43nos.Route(e.OnFIX43NewOrderSingle))
-	e.AddRoute(fix44nos.Route(e.OnFIX44NewOrderSingle))
-	e.AddRoute(fix50nos.Route(e.OnFIX50NewOrderSingle))
+func newExecutor(behaviors *behaviors, prices *priceFeed, master *instruments.Master) *executor {
+	e := &executor{
+		orders:        make(map[orderKey]*order),
+		closed:        make(map[orderKey]*order),
+		behaviors:     behaviors,
+		prices:        prices,
+		instruments:   master,
+		MessageRouter: quickfix.NewMessageRouter(),
+	}
+	e.AddRoute(fix40nos.Route(messages.Route[fix40nos.NewOrderSingle](e.onNewOrderSingle)))
+	e.AddRoute(fix41nos.Route(messages.Route[fix41nos.NewOrderSingle](e.onNewOrderSingle)))
+	e.AddRoute(fix42nos.Route(messages.Route[fix42nos.NewOrderSingle](e.onNewOrderSingle)))
+	e.AddRoute(fix43nos.Route(messages.Route[fix43nos.NewOrderSingle](e.onNewOrderSingle)))
+	e.AddRoute(fix44nos.Route(messages.Route[fix44nos.NewOrderSingle](e.onNewOrderSingle)))
+	e.AddRoute(fix50nos.Route(messages.Route[fix50nos.NewOrderSingle](e.onNewOrderSingle)))
+
+	e.AddRoute(fix40cxl.Route(messages.Route[fix40cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+	e.AddRoute(fix41cxl.Route(messages.Route[fix41cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+	e.AddRoute(fix42cxl.Route(messages.Route[fix42cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+	e.AddRoute(fix43cxl.Route(messages.Route[fix43cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+	e.AddRoute(fix44cxl.Route(messages.Route[fix44cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+	e.AddRoute(fix50cxl.Route(messages.Route[fix50cxl.OrderCancelRequest](e.onOrderCancelRequest)))
+
+	e.AddRoute(fix40ocrr.Route(messages.Route[fix40ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+	e.AddRoute(fix41ocrr.Route(messages.Route[fix41ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+	e.AddRoute(fix42ocrr.Route(messages.Route[fix42ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+	e.AddRoute(fix43ocrr.Route(messages.Route[fix43ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+	e.AddRoute(fix44ocrr.Route(messages.Route[fix44ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+	e.AddRoute(fix50ocrr.Route(messages.Route[fix50ocrr.OrderCancelReplaceRequest](e.onOrderCancelReplaceRequest)))
+
+	if master != nil {
+		master.AddRoutes(e.MessageRouter)
+	}
 
 	return e
 }
@@ -78,413 +150,315 @@ func (e *executor) genExecID() field.ExecIDField {
 	return field.NewExecID(strconv.Itoa(e.execID))
 }
 
-// quickfix.Application interface
-func (e executor) OnCreate(_ quickfix.SessionID)                         {}
-func (e executor) OnLogon(_ quickfix.SessionID)                          {}
-func (e executor) OnLogout(_ quickfix.SessionID)                         {}
-func (e executor) ToAdmin(_ *quickfix.Message, _ quickfix.SessionID)     {}
-func (e executor) ToApp(_ *quickfix.Message, _ quickfix.SessionID) error { return nil }
-func (e executor) FromAdmin(_ *quickfix.Message, _ quickfix.SessionID) quickfix.MessageRejectError {
-	return nil
-}
-
-// Use Message Cracker on Incoming Application Messages
-func (e *executor) FromApp(msg *quickfix.Message, sessionID quickfix.SessionID) (reject quickfix.MessageRejectError) {
-	return e.Route(msg, sessionID)
-}
-
-func (e *executor) OnFIX40NewOrderSingle(msg fix40nos.NewOrderSingle, sessionID quickfix.SessionID) quickfix.MessageRejectError {
-	ordType, err := msg.GetOrdType()
-	if err != nil {
-		return err
+// isSupported returns true for the order types the executor accepts
+func isSupported(ordType enum.OrdType) bool {
+	switch ordType {
+	case enum.OrdType_LIMIT, enum.OrdType_MARKET, enum.OrdType_STOP, enum.OrdType_STOP_LIMIT:
+		return true
 	}
 
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
-		return quickfix.ValueIsIncorrect(tag.OrdType)
-	}
+	return false
+}
 
-	symbol, err := msg.GetSymbol()
-	if err != nil {
-		return err
+// orderPrices returns the limit and stop price an order of ordType requires
+func orderPrices(msg newOrderSingle, ordType enum.OrdType) (price, stopPx decimal.Decimal, err quickfix.MessageRejectError) {
+	switch ordType {
+	case enum.OrdType_LIMIT, enum.OrdType_STOP_LIMIT:
+		if price, err = msg.GetPrice(); err != nil {
+			return
+		}
 	}
 
-	side, err := msg.GetSide()
-	if err != nil {
-		return err
+	switch ordType {
+	case enum.OrdType_STOP, enum.OrdType_STOP_LIMIT:
+		stopPx, err = msg.GetStopPx()
 	}
 
-	orderQty, err := msg.GetOrderQty()
-	if err != nil {
-		return err
-	}
+	return
+}
 
-	price, err := msg.GetPrice()
-	if err != nil {
-		return err
+// checkOrder returns an error if o does not conform to the instrument master
+func (e *executor) checkOrder(o *order) error {
+	var prices []decimal.Decimal
+	switch o.ordType {
+	case enum.OrdType_LIMIT:
+		prices = append(prices, o.price)
+	case enum.OrdType_STOP:
+		prices = append(prices, o.stopPx)
+	case enum.OrdType_STOP_LIMIT:
+		prices = append(prices, o.price, o.stopPx)
 	}
 
-	execReport := fix40er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecTransType(enum.ExecTransType_NEW),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSymbol(symbol),
-		field.NewSide(side),
-		field.NewOrderQty(orderQty, 2),
-		field.NewLastShares(orderQty, 2),
-		field.NewLastPx(price, 2),
-		field.NewCumQty(orderQty, 2),
-		field.NewAvgPx(price, 2),
-	)
-
-	clOrdID, err := msg.GetClOrdID()
-	if err != nil {
-		return err
-	}
-	execReport.SetClOrdID(clOrdID)
+	return e.instruments.CheckOrder(o.symbol, o.quantity, prices...)
+}
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
-	}
+// execute answers a new order according to the behavior matching its symbol and account
+func (e *executor) execute(o *order) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	return nil
-}
+	b := e.behaviors.match(o.symbol, o.account)
 
-func (e *executor) OnFIX41NewOrderSingle(msg fix41nos.NewOrderSingle, sessionID quickfix.SessionID) (err quickfix.MessageRejectError) {
-	ordType, err := msg.GetOrdType()
-	if err != nil {
+	if e.known(o.key()) {
+		// a duplicate order reason is only defined from FIX 4.1 on
+		reason := enum.OrdRejReason_DUPLICATE_ORDER
+		if o.sessionID.BeginString == quickfix.BeginStringFIX40 {
+			reason = enum.OrdRejReason_BROKER
+		}
+		e.reject(o, reason, errDuplicateClOrdID.Error())
 		return
 	}
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
-		return quickfix.ValueIsIncorrect(tag.OrdType)
-	}
 
-	symbol, err := msg.GetSymbol()
-	if err != nil {
+	if err := e.checkOrder(o); err != nil {
+		var instrumentErr instruments.Error
+		errors.As(err, &instrumentErr)
+		e.reject(o, instrumentErr.ReasonFor(o.sessionID.BeginString), err.Error())
 		return
 	}
 
-	side, err := msg.GetSide()
-	if err != nil {
+	if b.RejectRate > 0 && rand.Float64() < b.RejectRate {
+		text := b.RejectText
+		if text == "" {
+			text = "order rejected"
+		}
+		e.reject(o, enum.OrdRejReason_BROKER, text)
 		return
 	}
 
-	orderQty, err := msg.GetOrderQty()
-	if err != nil {
+	price, hasPrice := e.prices.price(o.symbol)
+	if o.isMarket() && !hasPrice {
+		e.reject(o, enum.OrdRejReason_BROKER, fmt.Sprintf("no reference price for %v", o.symbol))
 		return
 	}
 
-	price, err := msg.GetPrice()
-	if err != nil {
+	// only an accepted order uses up an order ID
+	o.orderID = e.genOrderID().Value()
+	e.orders[o.key()] = o
+	if o.isStop() && !(hasPrice && o.crosses(price)) {
+		e.send(o, execution{execType: enum.ExecType_NEW, ordStatus: enum.OrdStatus_NEW})
 		return
 	}
+	o.triggered = o.isStop()
 
-	execReport := fix41er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecTransType(enum.ExecTransType_NEW),
-		field.NewExecType(enum.ExecType_FILL),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSymbol(symbol),
-		field.NewSide(side),
-		field.NewOrderQty(orderQty, 2),
-		field.NewLastShares(orderQty, 2),
-		field.NewLastPx(price, 2),
-		field.NewLeavesQty(decimal.Zero, 2),
-		field.NewCumQty(orderQty, 2),
-		field.NewAvgPx(price, 2),
-	)
-
-	clOrdID, err := msg.GetClOrdID()
-	if err != nil {
+	if b.isImmediate() {
+		e.fill(o, o.quantity, e.fillPrice(o, b))
 		return
 	}
-	execReport.SetClOrdID(clOrdID)
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
-	}
-	return
+	e.send(o, execution{execType: enum.ExecType_NEW, ordStatus: enum.OrdStatus_NEW})
+	e.work(o, b)
 }
 
-func (e *executor) OnFIX42NewOrderSingle(msg fix42nos.NewOrderSingle, sessionID quickfix.SessionID) (err quickfix.MessageRejectError) {
-	ordType, err := msg.GetOrdType()
-	if err != nil {
-		return err
-	}
+// trigger works a stop order the price has crossed, it has been acknowledged already
+func (e *executor) trigger(o *order) {
+	o.triggered = true
 
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
-		return quickfix.ValueIsIncorrect(tag.OrdType)
-	}
-
-	symbol, err := msg.GetSymbol()
-	if err != nil {
+	b := e.behaviors.match(o.symbol, o.account)
+	if b.isImmediate() {
+		e.fill(o, o.quantity, e.fillPrice(o, b))
 		return
 	}
 
-	side, err := msg.GetSide()
-	if err != nil {
-		return
-	}
-
-	orderQty, err := msg.GetOrderQty()
-	if err != nil {
-		return
-	}
-
-	price, err := msg.GetPrice()
-	if err != nil {
-		return
-	}
+	e.work(o, b)
+}
 
-	clOrdID, err := msg.GetClOrdID()
-	if err != nil {
+// work schedules the fills of an acknowledged order
+func (e *executor) work(o *order, b behavior) {
+	if b.Action == actionAck {
 		return
 	}
 
-	execReport := fix42er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecTransType(enum.ExecTransType_NEW),
-		field.NewExecType(enum.ExecType_FILL),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSymbol(symbol),
-		field.NewSide(side),
-		field.NewLeavesQty(decimal.Zero, 2),
-		field.NewCumQty(orderQty, 2),
-		field.NewAvgPx(price, 2),
-	)
-
-	execReport.SetClOrdID(clOrdID)
-	execReport.SetOrderQty(orderQty, 2)
-	execReport.SetLastShares(orderQty, 2)
-	execReport.SetLastPx(price, 2)
+	slices := max(b.Slices, 1)
+	for i := 1; i <= slices; i++ {
+		time.AfterFunc(b.Delay+time.Duration(i-1)*b.Interval, func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
 
-	if msg.HasAccount() {
-		acct, err := msg.GetAccount()
-		if err != nil {
-			return err
-		}
-		execReport.SetAccount(acct)
-	}
+			// each slice fills up to its share of the order as it stands after any replace,
+			// the last one whatever is left
+			target := o.quantity.Mul(decimal.NewFromInt(int64(i))).Div(decimal.NewFromInt(int64(slices))).Truncate(0)
+			if i == slices {
+				target = o.quantity
+			}
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
+			quantity := target.Sub(o.cumQty)
+			if o.closed || !quantity.IsPositive() {
+				return
+			}
+			e.fill(o, quantity, e.fillPrice(o, b))
+		})
 	}
-
-	return
 }
 
-func (e *executor) OnFIX43NewOrderSingle(msg fix43nos.NewOrderSingle, sessionID quickfix.SessionID) (err quickfix.MessageRejectError) {
-	ordType, err := msg.GetOrdType()
-	if err != nil {
-		return err
-	}
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
-		return quickfix.ValueIsIncorrect(tag.OrdType)
-	}
-
-	symbol, err := msg.GetSymbol()
-	if err != nil {
-		return
-	}
-
-	side, err := msg.GetSide()
-	if err != nil {
-		return
-	}
-
-	orderQty, err := msg.GetOrderQty()
-	if err != nil {
-		return
-	}
-
-	price, err := msg.GetPrice()
-	if err != nil {
-		return
+// fillPrice returns the price o fills at now, the reference price of its symbol for market
+// orders and its limit price otherwise, moved by the offset of b in favor of o: up for a sell,
+// down for a buy. A limit order never fills through its limit.
+func (e *executor) fillPrice(o *order, b behavior) decimal.Decimal {
+	if o.isMarket() {
+		price, _ := e.prices.price(o.symbol)
+		if o.side == enum.Side_BUY {
+			return price.Sub(b.PriceOffset)
+		}
+		return price.Add(b.PriceOffset)
 	}
 
-	clOrdID, err := msg.GetClOrdID()
-	if err != nil {
-		return
+	if o.side == enum.Side_BUY {
+		return decimal.Min(o.price.Sub(b.PriceOffset), o.price)
 	}
+	return decimal.Max(o.price.Add(b.PriceOffset), o.price)
+}
 
-	execReport := fix43er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecType(enum.ExecType_FILL),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSide(side),
-		field.NewLeavesQty(decimal.Zero, 2),
-		field.NewCumQty(orderQty, 2),
-		field.NewAvgPx(price, 2),
-	)
-
-	execReport.SetClOrdID(clOrdID)
-	execReport.SetSymbol(symbol)
-	execReport.SetOrderQty(orderQty, 2)
-	execReport.SetLastQty(orderQty, 2)
-	execReport.SetLastPx(price, 2)
+// onPrice moves the reference price of symbol and triggers the stop orders it crosses
+func (e *executor) onPrice(symbol string, price decimal.Decimal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	if msg.HasAccount() {
-		acct, err := msg.GetAccount()
-		if err != nil {
-			return err
+	e.prices.Reference[symbol] = price
+	for _, o := range e.orders {
+		if o.symbol == symbol && o.isStop() && !o.triggered && o.crosses(price) {
+			e.trigger(o)
 		}
-		execReport.SetAccount(acct)
 	}
+}
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
+// reject refuses o for reason, explained by text. A refused order has no order ID, its ClOrdID
+// is used up all the same.
+func (e *executor) reject(o *order, reason enum.OrdRejReason, text string) {
+	o.orderID, o.closed, o.rejected = "NONE", true, true
+	if !e.known(o.key()) {
+		e.remember(o.key(), o)
 	}
+	e.send(o, execution{execType: enum.ExecType_REJECTED, ordStatus: enum.OrdStatus_REJECTED, ordRejReason: reason, text: text})
+}
 
-	return
+// close moves o from the open orders to the closed ones
+func (e *executor) close(o *order) {
+	delete(e.orders, o.key())
+	e.remember(o.key(), o)
 }
 
-func (e *executor) OnFIX44NewOrderSingle(msg fix44nos.NewOrderSingle, sessionID quickfix.SessionID) (err quickfix.MessageRejectError) {
-	ordType, err := msg.GetOrdType()
-	if err != nil {
-		return err
+// remember keeps o under key, a ClOrdID of its session no longer open, forgetting the oldest
+// closed order once closedOrdersKept are remembered
+func (e *executor) remember(key orderKey, o *order) {
+	if _, ok := e.closed[key]; !ok {
+		e.closedKeys = append(e.closedKeys, key)
 	}
+	e.closed[key] = o
 
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
-		return quickfix.ValueIsIncorrect(tag.OrdType)
+	for len(e.closedKeys) > closedOrdersKept {
+		delete(e.closed, e.closedKeys[0])
+		e.closedKeys[0] = orderKey{}
+		e.closedKeys = e.closedKeys[1:]
 	}
+}
 
-	symbol, err := msg.GetSymbol()
-	if err != nil {
-		return
-	}
+// known returns true if the session has an open or closed order under key
+func (e *executor) known(key orderKey) bool {
+	_, open := e.orders[key]
+	_, closed := e.closed[key]
+	return open || closed
+}
 
-	side, err := msg.GetSide()
-	if err != nil {
-		return
+// notOpen returns why there is no open order with key
+func (e *executor) notOpen(key orderKey) error {
+	if o := e.closed[key]; o != nil {
+		return errTooLateToCancel
 	}
 
-	orderQty, err := msg.GetOrderQty()
-	if err != nil {
-		return
-	}
+	return errUnknownOrder
+}
 
-	price, err := msg.GetPrice()
-	if err != nil {
-		return
+// fill executes quantity of o at price and reports it
+func (e *executor) fill(o *order, quantity, price decimal.Decimal) {
+	o.fill(quantity, price)
+	if o.closed {
+		e.close(o)
 	}
 
-	clOrdID, err := msg.GetClOrdID()
-	if err != nil {
-		return
+	x := execution{execType: enum.ExecType_PARTIAL_FILL, ordStatus: enum.OrdStatus_PARTIALLY_FILLED, lastQty: quantity, lastPx: price}
+	if o.closed {
+		x.execType, x.ordStatus = enum.ExecType_FILL, enum.OrdStatus_FILLED
 	}
+	e.send(o, x)
+}
 
-	execReport := fix44er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecType(enum.ExecType_FILL),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSide(side),
-		field.NewLeavesQty(decimal.Zero, 2),
-		field.NewCumQty(orderQty, 2),
-		field.NewAvgPx(price, 2),
-	)
-
-	execReport.SetClOrdID(clOrdID)
-	execReport.SetSymbol(symbol)
-	execReport.SetOrderQty(orderQty, 2)
-	execReport.SetLastQty(orderQty, 2)
-	execReport.SetLastPx(price, 2)
-
-	if msg.HasAccount() {
-		acct, err := msg.GetAccount()
-		if err != nil {
-			return err
-		}
-		execReport.SetAccount(acct)
+func (e *executor) send(o *order, x execution) {
+	if err := quickfix.SendToTarget(e.newExecutionReport(o, x), o.sessionID); err != nil {
+		utils.PrintBad(err.Error())
 	}
+}
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
-	}
+// quickfix.Application interface
+func (e *executor) OnCreate(_ quickfix.SessionID)                         {}
+func (e *executor) OnLogon(_ quickfix.SessionID)                          {}
+func (e *executor) OnLogout(_ quickfix.SessionID)                         {}
+func (e *executor) ToAdmin(_ *quickfix.Message, _ quickfix.SessionID)     {}
+func (e *executor) ToApp(_ *quickfix.Message, _ quickfix.SessionID) error { return nil }
+func (e *executor) FromAdmin(_ *quickfix.Message, _ quickfix.SessionID) quickfix.MessageRejectError {
+	return nil
+}
 
-	return
+// Use Message Cracker on Incoming Application Messages
+func (e *executor) FromApp(msg *quickfix.Message, sessionID quickfix.SessionID) (reject quickfix.MessageRejectError) {
+	return e.Route(msg, sessionID)
 }
 
-func (e *executor) OnFIX50NewOrderSingle(msg fix50nos.NewOrderSingle, sessionID quickfix.SessionID) (err quickfix.MessageRejectError) {
+func (e *executor) onNewOrderSingle(msg newOrderSingle, sessionID quickfix.SessionID) quickfix.MessageRejectError {
 	ordType, err := msg.GetOrdType()
 	if err != nil {
 		return err
 	}
 
-	if ordType != enum.OrdType_LIMIT {
-		utils.PrintBad("incoming order was not a limit order and was rejected")
+	if !isSupported(ordType) {
+		utils.PrintBad("incoming order type is not supported and was rejected")
 		return quickfix.ValueIsIncorrect(tag.OrdType)
 	}
 
-	symbol, err := msg.GetSymbol()
+	clOrdID, err := msg.GetClOrdID()
 	if err != nil {
-		return
+		return err
 	}
 
-	side, err := msg.GetSide()
+	symbol, err := msg.GetSymbol()
 	if err != nil {
-		return
+		return err
 	}
 
-	orderQty, err := msg.GetOrderQty()
+	side, err := msg.GetSide()
 	if err != nil {
-		return
+		return err
 	}
 
-	price, err := msg.GetPrice()
+	orderQty, err := msg.GetOrderQty()
 	if err != nil {
-		return
+		return err
 	}
 
-	clOrdID, err := msg.GetClOrdID()
+	price, stopPx, err := orderPrices(msg, ordType)
 	if err != nil {
-		return
+		return err
 	}
 
-	execReport := fix50er.New(
-		e.genOrderID(),
-		e.genExecID(),
-		field.NewExecType(enum.ExecType_FILL),
-		field.NewOrdStatus(enum.OrdStatus_FILLED),
-		field.NewSide(side),
-		field.NewLeavesQty(decimal.Zero, 2),
-		field.NewCumQty(orderQty, 2),
-	)
-
-	execReport.SetClOrdID(clOrdID)
-	execReport.SetSymbol(symbol)
-	execReport.SetOrderQty(orderQty, 2)
-	execReport.SetLastQty(orderQty, 2)
-	execReport.SetLastPx(price, 2)
-	execReport.SetAvgPx(price, 2)
-
+	var account string
 	if msg.HasAccount() {
-		acct, err := msg.GetAccount()
-		if err != nil {
+		if account, err = msg.GetAccount(); err != nil {
 			return err
 		}
-		execReport.SetAccount(acct)
 	}
 
-	sendErr := quickfix.SendToTarget(execReport, sessionID)
-	if sendErr != nil {
-		utils.PrintBad(sendErr.Error())
-	}
+	e.execute(&order{
+		sessionID: sessionID,
+		clOrdID:   clOrdID,
+		symbol:    symbol,
+		account:   account,
+		side:      side,
+		ordType:   ordType,
+		price:     price,
+		stopPx:    stopPx,
+		quantity:  orderQty,
+	})
 
-	return
+	return nil
 }
 
 const (
@@ -503,8 +477,27 @@ var (
 		Example: "qf executor [YOUR_FIX_CONFIG_FILE_HERE.cfg] (default is ./config/executor.cfg)",
 		RunE:    execute,
 	}
+
+	// storeF overrides the message store picked by the config file.
+	storeF string
+
+	// behaviorsF is the file describing how orders are answered.
+	behaviorsF string
+
+	// pricesF is the file holding the reference prices and price feed.
+	pricesF string
+
+	// instrumentsF is the instrument master, the symbols orders are accepted in.
+	instrumentsF string
 )
 
+func init() {
+	Cmd.Flags().StringVar(&storeF, "store", "", "message store to use: memory, file or sql (default picked from the config file)")
+	Cmd.Flags().StringVar(&pricesF, "prices", "", "YAML file with the reference prices market orders fill at and the feed stop orders trigger on")
+	Cmd.Flags().StringVar(&instrumentsF, "instruments", "", "YAML file listing the symbols orders are accepted in, with their tick and lot sizes (default accepts any symbol)")
+	Cmd.Flags().StringVar(&behaviorsF, "behaviors", "", "YAML file describing how orders are answered (default fills every order at once)")
+}
+
 func execute(_ *cobra.Command, args []string) error {
 	var cfgFileName string
 	argLen := len(args)
@@ -539,11 +532,37 @@ func execute(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("error reading cfg: %s,", err)
 	}
 
+	behaviors := defaultBehaviors()
+	if behaviorsF != "" {
+		if behaviors, err = loadBehaviors(behaviorsF); err != nil {
+			return err
+		}
+	}
+
+	prices := newPriceFeed()
+	if pricesF != "" {
+		if prices, err = loadPriceFeed(pricesF); err != nil {
+			return err
+		}
+	}
+
+	var master *instruments.Master
+	if instrumentsF != "" {
+		if master, err = instruments.Load(instrumentsF); err != nil {
+			return fmt.Errorf("error reading instruments %v: %s", instrumentsF, err)
+		}
+	}
+
 	logger := utils.NewFancyLog()
-	app := newExecutor()
+	app := newExecutor(behaviors, prices, master)
+
+	storeFactory, err := utils.NewStoreFactory(appSettings, storeF)
+	if err != nil {
+		return fmt.Errorf("unable to create message store: %s", err)
+	}
 
 	utils.PrintConfig("acceptor", bytes.NewReader(stringData))
-	acceptor, err := quickfix.NewAcceptor(app, quickfix.NewMemoryStoreFactory(), appSettings, logger)
+	acceptor, err := quickfix.NewAcceptor(app, storeFactory, appSettings, logger)
 	if err != nil {
 		return fmt.Errorf("unable to create acceptor: %s", err)
 	}
@@ -553,6 +572,8 @@ func execute(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("unable to start FIX acceptor: %s", err)
 	}
 
+	go prices.run(app.onPrice)
+
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 	<-interrupt
@@ -0,0 +1,110 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderIDsOfAcceptedOrders(t *testing.T) {
+	tests := []struct {
+		clOrdID string
+		ordType enum.OrdType
+		orderID string
+	}{
+		{"1", enum.OrdType_LIMIT, "1"},
+		{"1", enum.OrdType_LIMIT, "NONE"},
+		// a market order is refused without a reference price
+		{"2", enum.OrdType_MARKET, "NONE"},
+		{"2", enum.OrdType_LIMIT, "NONE"},
+		{"3", enum.OrdType_LIMIT, "2"},
+	}
+
+	e := newExecutor(defaultBehaviors(), newPriceFeed(), nil)
+	for _, test := range tests {
+		o := &order{
+			sessionID: testSession,
+			clOrdID:   test.clOrdID,
+			symbol:    "ABC",
+			side:      enum.Side_BUY,
+			ordType:   test.ordType,
+			price:     decimal.NewFromInt(10),
+			quantity:  decimal.NewFromInt(100),
+		}
+		e.execute(o)
+
+		if o.orderID != test.orderID {
+			t.Errorf("%v %v: OrderID %v, want %v", test.clOrdID, test.ordType, o.orderID, test.orderID)
+		}
+		if !e.known(o.key()) {
+			t.Errorf("%v %v: ClOrdID may be used again", test.clOrdID, test.ordType)
+		}
+	}
+}
+
+func TestStopOrdersTrigger(t *testing.T) {
+	tests := []struct {
+		side    enum.Side
+		ordType enum.OrdType
+		stopPx  int64
+
+		// prices are the reference prices after the order arrives, the last one must trigger
+		// the stop and no other
+		prices []int64
+		avgPx  int64
+	}{
+		{enum.Side_BUY, enum.OrdType_STOP, 11, []int64{9, 10, 11}, 11},
+		{enum.Side_BUY, enum.OrdType_STOP, 11, []int64{12}, 12},
+		{enum.Side_SELL, enum.OrdType_STOP, 9, []int64{11, 10, 9}, 9},
+		{enum.Side_BUY, enum.OrdType_STOP_LIMIT, 11, []int64{10, 11}, 12},
+		{enum.Side_SELL, enum.OrdType_STOP_LIMIT, 9, []int64{8}, 12},
+	}
+
+	for _, test := range tests {
+		e := newExecutor(defaultBehaviors(), newPriceFeed(), nil)
+		e.prices.Reference["ABC"] = decimal.NewFromInt(10)
+
+		o := &order{
+			sessionID: testSession,
+			clOrdID:   "1",
+			symbol:    "ABC",
+			side:      test.side,
+			ordType:   test.ordType,
+			price:     decimal.NewFromInt(12),
+			stopPx:    decimal.NewFromInt(test.stopPx),
+			quantity:  decimal.NewFromInt(100),
+		}
+		e.execute(o)
+
+		for i, price := range test.prices {
+			if o.triggered || o.closed {
+				t.Fatalf("%v %v stop %v: triggered before %v", test.side, test.ordType, test.stopPx, test.prices[i:])
+			}
+			e.onPrice("ABC", decimal.NewFromInt(price))
+		}
+
+		if !o.triggered || !o.closed {
+			t.Errorf("%v %v stop %v: not filled after %v", test.side, test.ordType, test.stopPx, test.prices)
+			continue
+		}
+		if !o.avgPx().Equal(decimal.NewFromInt(test.avgPx)) {
+			t.Errorf("%v %v stop %v: filled at %v, want %v", test.side, test.ordType, test.stopPx, o.avgPx(), test.avgPx)
+		}
+	}
+}
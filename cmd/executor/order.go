@@ -0,0 +1,119 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+// orderKey identifies an open order by the session it came from and its current ClOrdID
+type orderKey struct {
+	sessionID quickfix.SessionID
+	clOrdID   string
+}
+
+// order is an order the executor has accepted
+type order struct {
+	sessionID quickfix.SessionID
+
+	orderID     string
+	clOrdID     string
+	origClOrdID string
+	symbol      string
+	account     string
+	side        enum.Side
+	ordType     enum.OrdType
+	price       decimal.Decimal
+	stopPx      decimal.Decimal
+	quantity    decimal.Decimal
+
+	// triggered is set once the price crosses the StopPx of a stop order
+	triggered bool
+
+	cumQty   decimal.Decimal
+	notional decimal.Decimal
+	closed   bool
+	canceled bool
+	rejected bool
+}
+
+func (o order) key() orderKey {
+	return orderKey{o.sessionID, o.clOrdID}
+}
+
+// isStop returns true for the order types waiting on StopPx
+func (o order) isStop() bool {
+	return o.ordType == enum.OrdType_STOP || o.ordType == enum.OrdType_STOP_LIMIT
+}
+
+// isMarket returns true if the order fills at the reference price, a market order or a
+// triggered stop order
+func (o order) isMarket() bool {
+	return o.ordType == enum.OrdType_MARKET || o.ordType == enum.OrdType_STOP
+}
+
+// crosses returns true if price triggers the stop order, a buy stop at or above StopPx and
+// a sell stop at or below it
+func (o order) crosses(price decimal.Decimal) bool {
+	if o.side == enum.Side_BUY {
+		return price.GreaterThanOrEqual(o.stopPx)
+	}
+
+	return price.LessThanOrEqual(o.stopPx)
+}
+
+// status returns the current OrdStatus of the order
+func (o order) status() enum.OrdStatus {
+	switch {
+	case o.rejected:
+		return enum.OrdStatus_REJECTED
+	case o.canceled:
+		return enum.OrdStatus_CANCELED
+	case o.closed:
+		return enum.OrdStatus_FILLED
+	case o.cumQty.IsPositive():
+		return enum.OrdStatus_PARTIALLY_FILLED
+	}
+
+	return enum.OrdStatus_NEW
+}
+
+func (o order) leavesQty() decimal.Decimal {
+	if o.closed {
+		return decimal.Zero
+	}
+
+	return o.quantity.Sub(o.cumQty)
+}
+
+func (o order) avgPx() decimal.Decimal {
+	if o.cumQty.IsZero() {
+		return decimal.Zero
+	}
+
+	return o.notional.Div(o.cumQty)
+}
+
+// fill executes quantity of the order at price
+func (o *order) fill(quantity, price decimal.Decimal) {
+	o.cumQty = o.cumQty.Add(quantity)
+	o.notional = o.notional.Add(quantity.Mul(price))
+	if o.cumQty.GreaterThanOrEqual(o.quantity) {
+		o.closed = true
+	}
+}
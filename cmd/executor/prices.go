@@ -0,0 +1,104 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// priceFeed holds the reference price of each symbol, which market orders fill at and stop
+// orders trigger on, and the prices it moves through over time
+type priceFeed struct {
+	// Reference is the current price of each symbol
+	Reference map[string]decimal.Decimal `yaml:"reference"`
+
+	// Feed lists the prices each symbol moves to, one every Interval, starting over if Loop
+	// is set
+	Feed     map[string][]decimal.Decimal `yaml:"feed"`
+	Interval time.Duration                `yaml:"interval"`
+	Loop     bool                         `yaml:"loop"`
+}
+
+// newPriceFeed returns a feed without any prices, as the executor runs without a prices file
+func newPriceFeed() *priceFeed {
+	return &priceFeed{Reference: make(map[string]decimal.Decimal)}
+}
+
+// loadPriceFeed reads the prices file at fileName
+func loadPriceFeed(fileName string) (*priceFeed, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p := newPriceFeed()
+	if err := yaml.NewDecoder(file).Decode(p); err != nil {
+		return nil, fmt.Errorf("error reading prices %v: %s", fileName, err)
+	}
+
+	if p.Reference == nil {
+		p.Reference = make(map[string]decimal.Decimal)
+	}
+
+	if len(p.Feed) > 0 && p.Interval <= 0 {
+		return nil, fmt.Errorf("error reading prices %v: feed needs a positive interval", fileName)
+	}
+
+	return p, nil
+}
+
+// price returns the reference price of symbol, false if it has none
+func (p *priceFeed) price(symbol string) (decimal.Decimal, bool) {
+	price, ok := p.Reference[symbol]
+	return price, ok
+}
+
+// run moves each symbol through its feed, calling tick with every new price, until the feed
+// is exhausted. tick is responsible for storing the price.
+func (p *priceFeed) run(tick func(symbol string, price decimal.Decimal)) {
+	if len(p.Feed) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for n := 0; ; n++ {
+		<-ticker.C
+
+		moved := false
+		for symbol, prices := range p.Feed {
+			switch {
+			case n < len(prices):
+				tick(symbol, prices[n])
+				moved = true
+			case p.Loop:
+				tick(symbol, prices[n%len(prices)])
+				moved = true
+			}
+		}
+
+		if !moved {
+			return
+		}
+	}
+}
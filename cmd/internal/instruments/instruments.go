@@ -0,0 +1,200 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+// Package instruments holds the instrument master, the reference data of the symbols that may
+// be traded, and answers the security requests made of it.
+package instruments
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// Instrument is the reference data of a tradable symbol. A zero tick size, lot size or
+// quantity bound leaves that check off.
+type Instrument struct {
+	Symbol           string                `yaml:"symbol"`
+	SecurityID       string                `yaml:"securityID"`
+	SecurityIDSource enum.SecurityIDSource `yaml:"securityIDSource"`
+	Currency         string                `yaml:"currency"`
+
+	// TickSize is the increment prices move in, LotSize the increment quantities move in
+	TickSize decimal.Decimal `yaml:"tickSize"`
+	LotSize  decimal.Decimal `yaml:"lotSize"`
+
+	MinQty decimal.Decimal `yaml:"minQty"`
+	MaxQty decimal.Decimal `yaml:"maxQty"`
+
+	// PricePrecision is the most decimal places a price may have, unchecked when not set
+	PricePrecision *int32 `yaml:"pricePrecision"`
+}
+
+// Master is the instrument master, the symbols that may be traded
+type Master struct {
+	Instruments []Instrument `yaml:"instruments"`
+
+	bySymbol map[string]Instrument
+
+	// responseID numbers the answers to security requests
+	responseID atomic.Int64
+}
+
+// Load reads the instrument master at path
+func Load(path string) (*Master, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m := new(Master)
+	if err := yaml.NewDecoder(file).Decode(m); err != nil {
+		return nil, err
+	}
+
+	m.bySymbol = make(map[string]Instrument, len(m.Instruments))
+	for i, instrument := range m.Instruments {
+		if err := instrument.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := m.bySymbol[instrument.Symbol]; ok {
+			return nil, fmt.Errorf("symbol %v listed twice", instrument.Symbol)
+		}
+
+		if instrument.SecurityID != "" && instrument.SecurityIDSource == "" {
+			instrument.SecurityIDSource = enum.SecurityIDSource_EXCHANGE_SYMBOL
+		}
+		m.Instruments[i] = instrument
+		m.bySymbol[instrument.Symbol] = instrument
+	}
+
+	return m, nil
+}
+
+func (i Instrument) validate() error {
+	switch {
+	case i.Symbol == "":
+		return fmt.Errorf("instrument without a symbol")
+	case i.TickSize.IsNegative(), i.LotSize.IsNegative(), i.MinQty.IsNegative(), i.MaxQty.IsNegative():
+		return fmt.Errorf("%v: sizes and quantities may not be negative", i.Symbol)
+	case i.MaxQty.IsPositive() && i.MinQty.GreaterThan(i.MaxQty):
+		return fmt.Errorf("%v: minQty %v above maxQty %v", i.Symbol, i.MinQty, i.MaxQty)
+	case i.PricePrecision != nil && *i.PricePrecision < 0:
+		return fmt.Errorf("%v: pricePrecision may not be negative", i.Symbol)
+	}
+
+	return nil
+}
+
+// Lookup returns the instrument of symbol, false if it is not listed
+func (m *Master) Lookup(symbol string) (Instrument, bool) {
+	instrument, ok := m.bySymbol[symbol]
+	return instrument, ok
+}
+
+// Error is the reference data an order does not conform to
+type Error struct {
+	Reason enum.OrdRejReason
+	Text   string
+}
+
+func (e Error) Error() string {
+	return e.Text
+}
+
+// ReasonFor returns the OrdRejReason of the error in the FIX version beginString, BROKER where
+// the version does not define the reason
+func (e Error) ReasonFor(beginString string) enum.OrdRejReason {
+	switch e.Reason {
+	case enum.OrdRejReason_INCORRECT_QUANTITY:
+		// only defined from FIX 4.4 on
+		switch beginString {
+		case quickfix.BeginStringFIX44, quickfix.BeginStringFIXT11:
+			return e.Reason
+		}
+		return enum.OrdRejReason_BROKER
+
+	case enum.OrdRejReason_INVALID_PRICE_INCREMENT:
+		// only defined from FIX 5.0 on
+		if beginString == quickfix.BeginStringFIXT11 {
+			return e.Reason
+		}
+		return enum.OrdRejReason_BROKER
+	}
+
+	return e.Reason
+}
+
+// CheckOrder returns an Error if an order in symbol for qty at prices does not
+// conform to the instrument master, nil otherwise. A market order has no prices to check.
+// Without an instrument master every order conforms.
+func (m *Master) CheckOrder(symbol string, qty decimal.Decimal, prices ...decimal.Decimal) error {
+	if m == nil {
+		return nil
+	}
+
+	instrument, ok := m.Lookup(symbol)
+	if !ok {
+		return Error{Reason: enum.OrdRejReason_UNKNOWN_SYMBOL, Text: fmt.Sprintf("unknown symbol %v", symbol)}
+	}
+
+	for _, price := range prices {
+		if err := instrument.checkPrice(price); err != nil {
+			return err
+		}
+	}
+
+	return instrument.checkQty(qty)
+}
+
+func (i Instrument) checkPrice(price decimal.Decimal) error {
+	if i.PricePrecision != nil && !price.Round(*i.PricePrecision).Equal(price) {
+		return invalidPrice("price %v has more than %v decimal places", price, *i.PricePrecision)
+	}
+
+	if i.TickSize.IsPositive() && !price.Mod(i.TickSize).IsZero() {
+		return invalidPrice("price %v is not a multiple of the tick size %v", price, i.TickSize)
+	}
+
+	return nil
+}
+
+func (i Instrument) checkQty(qty decimal.Decimal) error {
+	switch {
+	case i.MinQty.IsPositive() && qty.LessThan(i.MinQty):
+		return incorrectQty("quantity %v below the minimum %v", qty, i.MinQty)
+	case i.MaxQty.IsPositive() && qty.GreaterThan(i.MaxQty):
+		return incorrectQty("quantity %v above the maximum %v", qty, i.MaxQty)
+	case i.LotSize.IsPositive() && !qty.Mod(i.LotSize).IsZero():
+		return incorrectQty("quantity %v is not a multiple of the lot size %v", qty, i.LotSize)
+	}
+
+	return nil
+}
+
+func invalidPrice(format string, args ...interface{}) Error {
+	return Error{Reason: enum.OrdRejReason_INVALID_PRICE_INCREMENT, Text: fmt.Sprintf(format, args...)}
+}
+
+func incorrectQty(format string, args ...interface{}) Error {
+	return Error{Reason: enum.OrdRejReason_INCORRECT_QUANTITY, Text: fmt.Sprintf(format, args...)}
+}
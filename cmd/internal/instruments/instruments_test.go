@@ -0,0 +1,142 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package instruments
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+// loadExample loads the example instrument master, which lists ABC and XYZ
+func loadExample(t *testing.T) *Master {
+	m, err := Load(filepath.Join("..", "..", "..", "config", "instruments.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestLoad(t *testing.T) {
+	m := loadExample(t)
+	instrument, ok := m.Lookup("ABC")
+	if !ok {
+		t.Fatal("ABC is not listed")
+	}
+	if instrument.SecurityIDSource != enum.SecurityIDSource_EXCHANGE_SYMBOL {
+		t.Errorf("SecurityIDSource %v, want the default %v", instrument.SecurityIDSource, enum.SecurityIDSource_EXCHANGE_SYMBOL)
+	}
+
+	tests := []struct {
+		name, yaml string
+	}{
+		{"no symbol", "instruments:\n  - tickSize: 0.01\n"},
+		{"negative tick size", "instruments:\n  - symbol: ABC\n    tickSize: -0.01\n"},
+		{"minQty above maxQty", "instruments:\n  - symbol: ABC\n    minQty: 10\n    maxQty: 5\n"},
+		{"negative pricePrecision", "instruments:\n  - symbol: ABC\n    pricePrecision: -1\n"},
+		{"symbol listed twice", "instruments:\n  - symbol: ABC\n  - symbol: ABC\n"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "instruments.yaml")
+		if err := os.WriteFile(path, []byte(test.yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%v: loaded without an error", test.name)
+		}
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	m := loadExample(t)
+
+	tests := []struct {
+		symbol string
+		qty    string
+		prices []string
+
+		// reason is the OrdRejReason of the Error returned, empty if the order conforms
+		reason enum.OrdRejReason
+	}{
+		{"ABC", "100", []string{"10.01"}, ""},
+		{"ABC", "100", nil, ""},
+		{"QQQ", "100", []string{"10"}, enum.OrdRejReason_UNKNOWN_SYMBOL},
+		{"ABC", "100", []string{"10.015"}, enum.OrdRejReason_INVALID_PRICE_INCREMENT},
+		{"XYZ", "100", []string{"10.05"}, ""},
+		{"XYZ", "100", []string{"10.02"}, enum.OrdRejReason_INVALID_PRICE_INCREMENT},
+		{"XYZ", "100", []string{"10.05", "10.02"}, enum.OrdRejReason_INVALID_PRICE_INCREMENT},
+		{"XYZ", "15", []string{"10.05"}, enum.OrdRejReason_INCORRECT_QUANTITY},
+		{"XYZ", "0", []string{"10.05"}, enum.OrdRejReason_INCORRECT_QUANTITY},
+		{"XYZ", "60000", []string{"10.05"}, enum.OrdRejReason_INCORRECT_QUANTITY},
+	}
+
+	for _, test := range tests {
+		var prices []decimal.Decimal
+		for _, price := range test.prices {
+			prices = append(prices, decimal.RequireFromString(price))
+		}
+
+		err := m.CheckOrder(test.symbol, decimal.RequireFromString(test.qty), prices...)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%v %v at %v: %v", test.symbol, test.qty, test.prices, err)
+			}
+			continue
+		}
+
+		var instrumentErr Error
+		if !errors.As(err, &instrumentErr) {
+			t.Errorf("%v %v at %v: got %v, want an Error", test.symbol, test.qty, test.prices, err)
+			continue
+		}
+		if instrumentErr.Reason != test.reason {
+			t.Errorf("%v %v at %v: reason %v, want %v", test.symbol, test.qty, test.prices, instrumentErr.Reason, test.reason)
+		}
+	}
+
+	var none *Master
+	if err := none.CheckOrder("QQQ", decimal.NewFromInt(1)); err != nil {
+		t.Errorf("without an instrument master: %v", err)
+	}
+}
+
+func TestReasonFor(t *testing.T) {
+	tests := []struct {
+		reason      enum.OrdRejReason
+		beginString string
+		want        enum.OrdRejReason
+	}{
+		{enum.OrdRejReason_UNKNOWN_SYMBOL, quickfix.BeginStringFIX40, enum.OrdRejReason_UNKNOWN_SYMBOL},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIX43, enum.OrdRejReason_BROKER},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIX44, enum.OrdRejReason_INCORRECT_QUANTITY},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIXT11, enum.OrdRejReason_INCORRECT_QUANTITY},
+		{enum.OrdRejReason_INVALID_PRICE_INCREMENT, quickfix.BeginStringFIX44, enum.OrdRejReason_BROKER},
+		{enum.OrdRejReason_INVALID_PRICE_INCREMENT, quickfix.BeginStringFIXT11, enum.OrdRejReason_INVALID_PRICE_INCREMENT},
+	}
+
+	for _, test := range tests {
+		if got := (Error{Reason: test.reason}).ReasonFor(test.beginString); got != test.want {
+			t.Errorf("%v on %v: got %v, want %v", test.reason, test.beginString, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package instruments
+
+import (
+	"strconv"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
+	"github.com/quickfixgo/examples/cmd/utils"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/tag"
+	"github.com/shopspring/decimal"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix42sdr "github.com/quickfixgo/fix42/securitydefinitionrequest"
+	fix43sdr "github.com/quickfixgo/fix43/securitydefinitionrequest"
+	fix44sdr "github.com/quickfixgo/fix44/securitydefinitionrequest"
+	fix50sdr "github.com/quickfixgo/fix50/securitydefinitionrequest"
+
+	fix43slr "github.com/quickfixgo/fix43/securitylistrequest"
+	fix44slr "github.com/quickfixgo/fix44/securitylistrequest"
+	fix50slr "github.com/quickfixgo/fix50/securitylistrequest"
+)
+
+// securityDefinitionRequest is the part of SecurityDefinitionRequest common to every supported FIX version
+type securityDefinitionRequest interface {
+	GetSecurityReqID() (string, quickfix.MessageRejectError)
+	GetSecurityRequestType() (enum.SecurityRequestType, quickfix.MessageRejectError)
+	HasSymbol() bool
+	GetSymbol() (string, quickfix.MessageRejectError)
+}
+
+// securityListRequest is the part of SecurityListRequest common to FIX 4.3 and later
+type securityListRequest interface {
+	GetSecurityReqID() (string, quickfix.MessageRejectError)
+	GetSecurityListRequestType() (enum.SecurityListRequestType, quickfix.MessageRejectError)
+	HasSymbol() bool
+	GetSymbol() (string, quickfix.MessageRejectError)
+}
+
+// secListInstruments is the NoRelatedSym group of a SecurityList, in the field order FIX 4.3
+// through FIX 5.0 share
+var secListInstruments = quickfix.GroupTemplate{
+	quickfix.GroupElement(tag.Symbol),
+	quickfix.GroupElement(tag.SecurityID),
+	quickfix.GroupElement(tag.SecurityIDSource),
+	quickfix.GroupElement(tag.MinPriceIncrement),
+	quickfix.GroupElement(tag.Currency),
+	quickfix.GroupElement(tag.RoundLot),
+	quickfix.GroupElement(tag.MinTradeVol),
+}
+
+// fieldSetter is a message body or a repeating group entry
+type fieldSetter interface {
+	Set(field quickfix.FieldWriter) *quickfix.FieldMap
+}
+
+// AddRoutes has router answer SecurityDefinitionRequests and SecurityListRequests with the
+// instruments of the master
+func (m *Master) AddRoutes(router *quickfix.MessageRouter) {
+	router.AddRoute(fix42sdr.Route(messages.Route[fix42sdr.SecurityDefinitionRequest](m.onSecurityDefinitionRequest)))
+	router.AddRoute(fix43sdr.Route(messages.Route[fix43sdr.SecurityDefinitionRequest](m.onSecurityDefinitionRequest)))
+	router.AddRoute(fix44sdr.Route(messages.Route[fix44sdr.SecurityDefinitionRequest](m.onSecurityDefinitionRequest)))
+	router.AddRoute(fix50sdr.Route(messages.Route[fix50sdr.SecurityDefinitionRequest](m.onSecurityDefinitionRequest)))
+
+	router.AddRoute(fix43slr.Route(messages.Route[fix43slr.SecurityListRequest](m.onSecurityListRequest)))
+	router.AddRoute(fix44slr.Route(messages.Route[fix44slr.SecurityListRequest](m.onSecurityListRequest)))
+	router.AddRoute(fix50slr.Route(messages.Route[fix50slr.SecurityListRequest](m.onSecurityListRequest)))
+}
+
+// onSecurityDefinitionRequest answers with the definition of the symbol asked for, or with
+// one definition per instrument when the request lists securities
+func (m *Master) onSecurityDefinitionRequest(msg securityDefinitionRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	securityReqID, err := msg.GetSecurityReqID()
+	if err != nil {
+		return err
+	}
+
+	requestType, err := msg.GetSecurityRequestType()
+	if err != nil {
+		return err
+	}
+
+	var symbol string
+	if msg.HasSymbol() {
+		if symbol, err = msg.GetSymbol(); err != nil {
+			return err
+		}
+	}
+
+	var definitions []*quickfix.Message
+	switch requestType {
+	case enum.SecurityRequestType_REQUEST_SECURITY_IDENTITY_AND_SPECIFICATIONS,
+		enum.SecurityRequestType_REQUEST_SECURITY_IDENTITY_FOR_THE_SPECIFICATIONS_PROVIDED:
+		instrument, ok := m.Lookup(symbol)
+		if !ok {
+			definition := m.newSecurityDefinition(securityReqID, enum.SecurityResponseType_CANNOT_MATCH_SELECTION_CRITERIA, Instrument{Symbol: symbol}, 0, sessionID.BeginString)
+			definition.Body.Set(field.NewText("unknown symbol"))
+			definitions = append(definitions, definition)
+			break
+		}
+		definitions = append(definitions, m.newSecurityDefinition(securityReqID, enum.SecurityResponseType_ACCEPT_SECURITY_PROPOSAL_AS_IS, instrument, 1, sessionID.BeginString))
+
+	case enum.SecurityRequestType_REQUEST_LIST_SECURITIES:
+		for _, instrument := range m.Instruments {
+			definitions = append(definitions, m.newSecurityDefinition(securityReqID, enum.SecurityResponseType_LIST_OF_SECURITIES_RETURNED_PER_REQUEST, instrument, len(m.Instruments), sessionID.BeginString))
+		}
+		if len(definitions) == 0 {
+			definitions = append(definitions, m.newSecurityDefinition(securityReqID, enum.SecurityResponseType_CANNOT_MATCH_SELECTION_CRITERIA, Instrument{}, 0, sessionID.BeginString))
+		}
+
+	default:
+		definition := m.newSecurityDefinition(securityReqID, enum.SecurityResponseType_REJECT_SECURITY_PROPOSAL, Instrument{Symbol: symbol}, 0, sessionID.BeginString)
+		definition.Body.Set(field.NewText("only security definitions and lists of securities are available"))
+		definitions = append(definitions, definition)
+	}
+
+	for _, definition := range definitions {
+		if err := quickfix.SendToTarget(definition, sessionID); err != nil {
+			utils.PrintBad(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// onSecurityListRequest answers with the instrument of the symbol asked for, or every instrument
+func (m *Master) onSecurityListRequest(msg securityListRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	securityReqID, err := msg.GetSecurityReqID()
+	if err != nil {
+		return err
+	}
+
+	listType, err := msg.GetSecurityListRequestType()
+	if err != nil {
+		return err
+	}
+
+	result := enum.SecurityRequestResult_VALID_REQUEST
+	var instruments []Instrument
+	switch listType {
+	case enum.SecurityListRequestType_SYMBOL:
+		var symbol string
+		if msg.HasSymbol() {
+			if symbol, err = msg.GetSymbol(); err != nil {
+				return err
+			}
+		}
+		if instrument, ok := m.Lookup(symbol); ok {
+			instruments = append(instruments, instrument)
+		}
+
+	case enum.SecurityListRequestType_ALL_SECURITIES:
+		instruments = m.Instruments
+
+	default:
+		result = enum.SecurityRequestResult_INVALID_OR_UNSUPPORTED_REQUEST
+	}
+
+	if result == enum.SecurityRequestResult_VALID_REQUEST && len(instruments) == 0 {
+		result = enum.SecurityRequestResult_NO_INSTRUMENTS_FOUND_THAT_MATCH_SELECTION_CRITERIA
+	}
+
+	if err := quickfix.SendToTarget(m.newSecurityList(securityReqID, result, instruments, sessionID.BeginString), sessionID); err != nil {
+		utils.PrintBad(err.Error())
+	}
+
+	return nil
+}
+
+// newSecurityDefinition builds the SecurityDefinition of instrument, one of total answering
+// the same request, for a session of FIX version beginString
+func (m *Master) newSecurityDefinition(securityReqID string, responseType enum.SecurityResponseType, instrument Instrument, total int, beginString string) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_SECURITY_DEFINITION))
+	msg.Body.Set(field.NewSecurityReqID(securityReqID))
+	msg.Body.Set(field.NewSecurityResponseID(m.nextResponseID()))
+	msg.Body.Set(field.NewSecurityResponseType(responseType))
+	if beginString == quickfix.BeginStringFIX42 {
+		msg.Body.Set(field.NewTotalNumSecurities(total))
+	}
+	setInstrument(&msg.Body, instrument, beginString)
+
+	return msg
+}
+
+// newSecurityList builds the SecurityList of instruments for a session of FIX version beginString
+func (m *Master) newSecurityList(securityReqID string, result enum.SecurityRequestResult, instruments []Instrument, beginString string) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_SECURITY_LIST))
+	msg.Body.Set(field.NewSecurityReqID(securityReqID))
+	msg.Body.Set(field.NewSecurityResponseID(m.nextResponseID()))
+	msg.Body.Set(field.NewSecurityRequestResult(result))
+
+	// the tag is TotalNumSecurities in FIX 4.3, which has no LastFragment
+	msg.Body.Set(field.NewTotNoRelatedSym(len(instruments)))
+	if beginString != quickfix.BeginStringFIX43 {
+		msg.Body.Set(field.NewLastFragment(true))
+	}
+
+	if len(instruments) > 0 {
+		related := quickfix.NewRepeatingGroup(tag.NoRelatedSym, secListInstruments)
+		for _, instrument := range instruments {
+			setInstrument(related.Add(), instrument, beginString)
+		}
+		msg.Body.SetGroup(related)
+	}
+
+	return msg
+}
+
+// setInstrument sets the reference data of instrument the FIX version beginString can carry
+func setInstrument(fields fieldSetter, instrument Instrument, beginString string) {
+	if instrument.Symbol != "" {
+		fields.Set(field.NewSymbol(instrument.Symbol))
+	}
+
+	if instrument.SecurityID != "" {
+		fields.Set(field.NewSecurityID(instrument.SecurityID))
+		fields.Set(field.NewSecurityIDSource(instrument.SecurityIDSource))
+	}
+
+	if instrument.Currency != "" {
+		fields.Set(field.NewCurrency(instrument.Currency))
+	}
+
+	// the tick size is only carried from FIX 5.0 on, the lot size and minimum from FIX 4.3 on
+	if beginString == quickfix.BeginStringFIXT11 && instrument.TickSize.IsPositive() {
+		fields.Set(field.NewMinPriceIncrement(instrument.TickSize, scale(instrument.TickSize)))
+	}
+
+	if beginString == quickfix.BeginStringFIX42 {
+		return
+	}
+
+	if instrument.LotSize.IsPositive() {
+		fields.Set(field.NewRoundLot(instrument.LotSize, scale(instrument.LotSize)))
+	}
+	if instrument.MinQty.IsPositive() {
+		fields.Set(field.NewMinTradeVol(instrument.MinQty, scale(instrument.MinQty)))
+	}
+}
+
+func (m *Master) nextResponseID() string {
+	return strconv.FormatInt(m.responseID.Add(1), 10)
+}
+
+// scale returns the decimal places d is written with
+func scale(d decimal.Decimal) int32 {
+	if exp := d.Exponent(); exp < 0 {
+		return -exp
+	}
+
+	return 0
+}
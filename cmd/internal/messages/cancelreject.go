@@ -0,0 +1,92 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package messages
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix40ocj "github.com/quickfixgo/fix40/ordercancelreject"
+	fix41ocj "github.com/quickfixgo/fix41/ordercancelreject"
+	fix42ocj "github.com/quickfixgo/fix42/ordercancelreject"
+	fix43ocj "github.com/quickfixgo/fix43/ordercancelreject"
+	fix44ocj "github.com/quickfixgo/fix44/ordercancelreject"
+	fix50ocj "github.com/quickfixgo/fix50/ordercancelreject"
+)
+
+// CancelRequest identifies the cancel or cancel/replace request being rejected
+type CancelRequest struct {
+	ClOrdID     string
+	OrigClOrdID string
+	ResponseTo  enum.CxlRejResponseTo
+}
+
+// NewOrderCancelReject builds the OrderCancelReject answering req in the FIX version
+// beginString. orderID and status are those of the order the request referred to, NONE and
+// REJECTED if there is no such order. A reason the version does not define is sent as BROKER,
+// or left off FIX 4.0 and 4.1, which have no BROKER.
+func NewOrderCancelReject(req CancelRequest, orderID string, status enum.OrdStatus, reason enum.CxlRejReason, text, beginString string) *quickfix.Message {
+	clOrdID := field.NewClOrdID(req.ClOrdID)
+	origClOrdID := field.NewOrigClOrdID(req.OrigClOrdID)
+	ordStatus := field.NewOrdStatus(status)
+	responseTo := field.NewCxlRejResponseTo(req.ResponseTo)
+
+	var msg *quickfix.Message
+	switch beginString {
+	// FIX 4.0 cancel rejects have no OrigClOrdID
+	case quickfix.BeginStringFIX40:
+		msg = fix40ocj.New(field.NewOrderID(orderID), clOrdID).ToMessage()
+
+	case quickfix.BeginStringFIX41:
+		msg = fix41ocj.New(field.NewOrderID(orderID), clOrdID, origClOrdID, ordStatus).ToMessage()
+
+	case quickfix.BeginStringFIX42:
+		msg = fix42ocj.New(field.NewOrderID(orderID), clOrdID, origClOrdID, ordStatus, responseTo).ToMessage()
+
+	case quickfix.BeginStringFIX43:
+		msg = fix43ocj.New(field.NewOrderID(orderID), clOrdID, origClOrdID, ordStatus, responseTo).ToMessage()
+
+	case quickfix.BeginStringFIX44:
+		msg = fix44ocj.New(field.NewOrderID(orderID), clOrdID, origClOrdID, ordStatus, responseTo).ToMessage()
+
+	default:
+		msg = fix50ocj.New(field.NewOrderID(orderID), clOrdID, origClOrdID, ordStatus, responseTo).ToMessage()
+	}
+
+	switch beginString {
+	// FIX 4.0 and 4.1 only define TOO_LATE_TO_CANCEL and UNKNOWN_ORDER, any other reason is
+	// left to the text
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41:
+		if reason == enum.CxlRejReason_TOO_LATE_TO_CANCEL || reason == enum.CxlRejReason_UNKNOWN_ORDER {
+			msg.Body.Set(field.NewCxlRejReason(reason))
+		}
+
+	// a duplicate ClOrdID is only defined from FIX 4.3 on
+	case quickfix.BeginStringFIX42:
+		if reason == enum.CxlRejReason_DUPLICATE_CLORDID {
+			reason = enum.CxlRejReason_BROKER
+		}
+		msg.Body.Set(field.NewCxlRejReason(reason))
+
+	default:
+		msg.Body.Set(field.NewCxlRejReason(reason))
+	}
+	msg.Body.Set(field.NewText(text))
+
+	return msg
+}
@@ -0,0 +1,73 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package messages
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
+)
+
+func TestNewOrderCancelReject(t *testing.T) {
+	req := CancelRequest{ClOrdID: "2", OrigClOrdID: "1", ResponseTo: enum.CxlRejResponseTo_ORDER_CANCEL_REPLACE_REQUEST}
+
+	tests := []struct {
+		beginString    string
+		reason         enum.CxlRejReason
+		hasOrigClOrdID bool
+
+		// want is the CxlRejReason sent, none if hasReason is false
+		hasReason bool
+		want      enum.CxlRejReason
+	}{
+		{quickfix.BeginStringFIX40, enum.CxlRejReason_DUPLICATE_CLORDID, false, false, ""},
+		{quickfix.BeginStringFIX40, enum.CxlRejReason_BROKER, false, false, ""},
+		{quickfix.BeginStringFIX40, enum.CxlRejReason_TOO_LATE_TO_CANCEL, false, true, enum.CxlRejReason_TOO_LATE_TO_CANCEL},
+		{quickfix.BeginStringFIX41, enum.CxlRejReason_DUPLICATE_CLORDID, true, false, ""},
+		{quickfix.BeginStringFIX41, enum.CxlRejReason_UNKNOWN_ORDER, true, true, enum.CxlRejReason_UNKNOWN_ORDER},
+		{quickfix.BeginStringFIX42, enum.CxlRejReason_DUPLICATE_CLORDID, true, true, enum.CxlRejReason_BROKER},
+		{quickfix.BeginStringFIX42, enum.CxlRejReason_BROKER, true, true, enum.CxlRejReason_BROKER},
+		{quickfix.BeginStringFIX43, enum.CxlRejReason_DUPLICATE_CLORDID, true, true, enum.CxlRejReason_DUPLICATE_CLORDID},
+		{quickfix.BeginStringFIXT11, enum.CxlRejReason_DUPLICATE_CLORDID, true, true, enum.CxlRejReason_DUPLICATE_CLORDID},
+	}
+
+	for _, test := range tests {
+		msg := NewOrderCancelReject(req, "NONE", enum.OrdStatus_REJECTED, test.reason, "rejected", test.beginString)
+
+		if has := msg.Body.Has(tag.OrigClOrdID); has != test.hasOrigClOrdID {
+			t.Errorf("%v: OrigClOrdID present %v, want %v", test.beginString, has, test.hasOrigClOrdID)
+		}
+
+		if has := msg.Body.Has(tag.CxlRejReason); has != test.hasReason {
+			t.Errorf("%v %v: CxlRejReason present %v, want %v", test.beginString, test.reason, has, test.hasReason)
+			continue
+		}
+		if !test.hasReason {
+			continue
+		}
+
+		var reason field.CxlRejReasonField
+		if err := msg.Body.Get(&reason); err != nil {
+			t.Fatalf("%v: %v", test.beginString, err)
+		}
+		if reason.Value() != test.want {
+			t.Errorf("%v %v: CxlRejReason %v, want %v", test.beginString, test.reason, reason.Value(), test.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+// Package messages holds the FIX message handling the acceptor commands share.
+package messages
+
+import (
+	"fmt"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// Route adapts handler, written against I, the part of a message common to every supported FIX
+// version, to the typed message T of one version. T must implement I, which is checked as the
+// route is set up rather than as messages arrive.
+func Route[T, I any](handler func(I, quickfix.SessionID) quickfix.MessageRejectError) func(T, quickfix.SessionID) quickfix.MessageRejectError {
+	var typed T
+	if _, ok := any(typed).(I); !ok {
+		panic(fmt.Sprintf("%T does not implement the message handled", typed))
+	}
+
+	return func(msg T, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+		return handler(any(msg).(I), sessionID)
+	}
+}
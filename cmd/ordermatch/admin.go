@@ -0,0 +1,337 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/shopspring/decimal"
+)
+
+// adminSymbol is a symbol with a book and its trading phase
+type adminSymbol struct {
+	Symbol string         `json:"symbol"`
+	Phase  internal.Phase `json:"phase"`
+}
+
+// adminLevel is the aggregated open quantity at one price
+type adminLevel struct {
+	Price    decimal.Decimal `json:"price"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Orders   int             `json:"orders"`
+}
+
+// adminBook is the aggregated book of a symbol, best prices first
+type adminBook struct {
+	adminSymbol
+	Bids   []adminLevel `json:"bids"`
+	Offers []adminLevel `json:"offers"`
+}
+
+// adminFill is one execution of an order
+type adminFill struct {
+	ExecID        string          `json:"execID"`
+	Price         decimal.Decimal `json:"price"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	ContraOrderID string          `json:"contraOrderID"`
+	ContraBroker  string          `json:"contraBroker"`
+	Time          string          `json:"time"`
+}
+
+// adminOrder is an order with its FIX side, type and status. Fills are only listed when a
+// single order is looked up.
+type adminOrder struct {
+	OrderID          string           `json:"orderID"`
+	ClOrdID          string           `json:"clOrdID"`
+	SenderCompID     string           `json:"senderCompID"`
+	Symbol           string           `json:"symbol"`
+	Side             enum.Side        `json:"side"`
+	OrdType          enum.OrdType     `json:"ordType"`
+	TimeInForce      enum.TimeInForce `json:"timeInForce,omitempty"`
+	Price            *decimal.Decimal `json:"price,omitempty"`
+	Quantity         decimal.Decimal  `json:"quantity"`
+	ExecutedQuantity decimal.Decimal  `json:"executedQuantity"`
+	OpenQuantity     decimal.Decimal  `json:"openQuantity"`
+	AvgPx            decimal.Decimal  `json:"avgPx"`
+	OrdStatus        enum.OrdStatus   `json:"ordStatus"`
+	Fills            []adminFill      `json:"fills,omitempty"`
+}
+
+// adminOrders lists the orders resting in the book of a symbol, in priority order
+type adminOrders struct {
+	adminSymbol
+	Bids   []adminOrder `json:"bids"`
+	Offers []adminOrder `json:"offers"`
+}
+
+// adminSession is a session of the config
+type adminSession struct {
+	SessionID    string `json:"sessionID"`
+	BeginString  string `json:"beginString"`
+	SenderCompID string `json:"senderCompID"`
+	TargetCompID string `json:"targetCompID"`
+	LoggedOn     bool   `json:"loggedOn"`
+	DropCopy     bool   `json:"dropCopy"`
+}
+
+// adminError is the body of every failed request
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// adminHandler serves the admin API, JSON over HTTP
+func (a *Application) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /symbols", a.onListSymbols)
+	mux.HandleFunc("GET /symbols/{symbol}/book", a.onGetBook)
+	mux.HandleFunc("GET /symbols/{symbol}/orders", a.onListOrders)
+	mux.HandleFunc("POST /symbols/{symbol}/halt", a.onHaltSymbol)
+	mux.HandleFunc("POST /symbols/{symbol}/resume", a.onResumeSymbol)
+	mux.HandleFunc("GET /orders/{orderID}", a.onGetOrder)
+	mux.HandleFunc("DELETE /orders/{orderID}", a.onCancelOrder)
+	mux.HandleFunc("GET /sessions", a.onListSessions)
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// session IDs hold a ->, which is kept readable
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, adminError{Error: err.Error()})
+}
+
+// phaseOf returns the trading phase of symbol
+func (a *Application) phaseOf(symbol string) internal.Phase {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.phases.Of(symbol)
+}
+
+func newAdminLevels(levels []internal.PriceLevel) []adminLevel {
+	converted := make([]adminLevel, 0, len(levels))
+	for _, level := range levels {
+		converted = append(converted, adminLevel{Price: level.Price, Quantity: level.Quantity, Orders: level.Orders})
+	}
+
+	return converted
+}
+
+func newAdminOrder(order internal.Order) adminOrder {
+	converted := adminOrder{
+		OrderID:          order.OrderID,
+		ClOrdID:          order.ClOrdID,
+		SenderCompID:     order.SenderCompID,
+		Symbol:           order.Symbol,
+		Side:             order.Side,
+		OrdType:          order.OrdType,
+		TimeInForce:      order.TimeInForce,
+		Quantity:         order.Quantity,
+		ExecutedQuantity: order.ExecutedQuantity,
+		OpenQuantity:     order.OpenQuantity(),
+		AvgPx:            order.AvgPx,
+		OrdStatus:        statusOf(order),
+	}
+	if !order.IsMarket() {
+		converted.Price = &order.Price
+	}
+
+	return converted
+}
+
+func newAdminOrders(orders []internal.Order) []adminOrder {
+	converted := make([]adminOrder, 0, len(orders))
+	for _, order := range orders {
+		converted = append(converted, newAdminOrder(order))
+	}
+
+	return converted
+}
+
+func (a *Application) onListSymbols(w http.ResponseWriter, _ *http.Request) {
+	symbols := a.Symbols()
+
+	a.mu.Lock()
+	listed := make([]adminSymbol, 0, len(symbols))
+	for _, symbol := range symbols {
+		listed = append(listed, adminSymbol{Symbol: symbol, Phase: a.phases.Of(symbol)})
+	}
+	a.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, listed)
+}
+
+// onGetBook answers with the aggregated book of a symbol, every level or as many as the depth
+// query parameter asks for
+func (a *Application) onGetBook(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	depth := 0
+	if value := r.URL.Query().Get("depth"); value != "" {
+		var err error
+		if depth, err = strconv.Atoi(value); err != nil || depth < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("depth %q is not a number of levels", value))
+			return
+		}
+	}
+
+	bids, offers, ok := a.Depth(symbol, depth)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown symbol %v", symbol))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, adminBook{
+		adminSymbol: adminSymbol{Symbol: symbol, Phase: a.phaseOf(symbol)},
+		Bids:        newAdminLevels(bids),
+		Offers:      newAdminLevels(offers),
+	})
+}
+
+func (a *Application) onListOrders(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+
+	bids, offers, ok := a.Orders(symbol)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown symbol %v", symbol))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, adminOrders{
+		adminSymbol: adminSymbol{Symbol: symbol, Phase: a.phaseOf(symbol)},
+		Bids:        newAdminOrders(bids),
+		Offers:      newAdminOrders(offers),
+	})
+}
+
+// onGetOrder answers with an order and its fills, whether it is resting or has left the book
+func (a *Application) onGetOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderID")
+
+	order := a.OrderByID(orderID)
+	if order == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown order %v", orderID))
+		return
+	}
+
+	converted := newAdminOrder(*order)
+	for _, fill := range order.Fills {
+		converted.Fills = append(converted.Fills, adminFill{
+			ExecID:        fill.ExecID,
+			Price:         fill.Price,
+			Quantity:      fill.Quantity,
+			ContraOrderID: fill.ContraOrderID,
+			ContraBroker:  fill.ContraBroker,
+			Time:          fill.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, converted)
+}
+
+// onCancelOrder cancels a resting order as the exchange, reporting the cancel to the firm that
+// entered it
+func (a *Application) onCancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderID")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	order := a.OrderByID(orderID)
+	if order == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown order %v", orderID))
+		return
+	}
+
+	canceled, err := a.Cancel(order.SenderCompID, order.ClOrdID, order.Symbol, order.Side)
+	switch {
+	case errors.Is(err, internal.ErrTooLateToCancel):
+		writeError(w, http.StatusConflict, fmt.Errorf("order %v: %s", orderID, err))
+		return
+	case errors.As(err, new(internal.JournalError)):
+		a.stop(err)
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("order %v: %s", orderID, err))
+		return
+	case err != nil:
+		writeError(w, http.StatusNotFound, fmt.Errorf("order %v: %s", orderID, err))
+		return
+	}
+
+	a.expireOrder(*canceled, "canceled by the exchange")
+	a.publishMarketData(canceled.Symbol, nil)
+	a.publishIndication(canceled.Symbol)
+
+	writeJSON(w, http.StatusOK, newAdminOrder(*canceled))
+}
+
+func (a *Application) onHaltSymbol(w http.ResponseWriter, r *http.Request) {
+	a.changeSymbol(w, r.PathValue("symbol"), a.halt)
+}
+
+func (a *Application) onResumeSymbol(w http.ResponseWriter, r *http.Request) {
+	a.changeSymbol(w, r.PathValue("symbol"), a.resume)
+}
+
+// changeSymbol halts or resumes symbol with change, answering with the phase it is left in
+func (a *Application) changeSymbol(w http.ResponseWriter, symbol string, change func(symbol string) error) {
+	if !a.listed(symbol) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown symbol %v", symbol))
+		return
+	}
+
+	if err := change(symbol); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, adminSymbol{Symbol: symbol, Phase: a.phaseOf(symbol)})
+}
+
+func (a *Application) onListSessions(w http.ResponseWriter, _ *http.Request) {
+	a.mu.Lock()
+	sessions := make([]adminSession, 0, len(a.sessions))
+	for sessionID, loggedOn := range a.sessions {
+		sessions = append(sessions, adminSession{
+			SessionID:    sessionID.String(),
+			BeginString:  sessionID.BeginString,
+			SenderCompID: sessionID.SenderCompID,
+			TargetCompID: sessionID.TargetCompID,
+			LoggedOn:     loggedOn,
+			DropCopy:     a.isDropCopy(sessionID),
+		})
+	}
+	a.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].SessionID < sessions[j].SessionID })
+	writeJSON(w, http.StatusOK, sessions)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+func TestAdminHandler(t *testing.T) {
+	a := newApplication()
+	a.sessions[quickfix.SessionID{BeginString: quickfix.BeginStringFIX44, SenderCompID: "ISLD", TargetCompID: "TW"}] = true
+	for i, side := range []enum.Side{enum.Side_BUY, enum.Side_SELL} {
+		order := internal.Order{
+			OrderID:      []string{"1", "2"}[i],
+			ClOrdID:      []string{"b", "s"}[i],
+			Symbol:       "ABC",
+			BeginString:  quickfix.BeginStringFIX44,
+			SenderCompID: "TW",
+			TargetCompID: "ISLD",
+			Side:         side,
+			OrdType:      enum.OrdType_LIMIT,
+			TimeInForce:  enum.TimeInForce_DAY,
+			Price:        decimal.NewFromInt(int64(10 + i)),
+			Quantity:     decimal.NewFromInt(100),
+		}
+		if err := a.Insert(order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := a.adminHandler()
+
+	// the requests run in order, each seeing what the earlier ones left
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/symbols", http.StatusOK},
+		{http.MethodGet, "/symbols/ABC/book", http.StatusOK},
+		{http.MethodGet, "/symbols/ABC/book?depth=1", http.StatusOK},
+		{http.MethodGet, "/symbols/ABC/book?depth=x", http.StatusBadRequest},
+		{http.MethodGet, "/symbols/QQQ/book", http.StatusNotFound},
+		{http.MethodGet, "/symbols/ABC/orders", http.StatusOK},
+		{http.MethodGet, "/symbols/QQQ/orders", http.StatusNotFound},
+		{http.MethodGet, "/orders/1", http.StatusOK},
+		{http.MethodGet, "/orders/9", http.StatusNotFound},
+		{http.MethodPost, "/symbols/ABC/halt", http.StatusOK},
+		{http.MethodPost, "/symbols/ABC/halt", http.StatusConflict},
+		{http.MethodPost, "/symbols/ABC/resume", http.StatusOK},
+		{http.MethodPost, "/symbols/ABC/resume", http.StatusConflict},
+		{http.MethodPost, "/symbols/QQQ/halt", http.StatusNotFound},
+		{http.MethodDelete, "/orders/1", http.StatusOK},
+		{http.MethodDelete, "/orders/1", http.StatusConflict},
+		{http.MethodGet, "/orders/1", http.StatusOK},
+		{http.MethodDelete, "/orders/9", http.StatusNotFound},
+		{http.MethodGet, "/sessions", http.StatusOK},
+		{http.MethodPut, "/sessions", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+		if recorder.Code != test.status {
+			t.Errorf("%v %v: status %v, want %v: %s", test.method, test.path, recorder.Code, test.status, recorder.Body)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); test.status != http.StatusMethodNotAllowed && contentType != "application/json" {
+			t.Errorf("%v %v: Content-Type %q", test.method, test.path, contentType)
+		}
+	}
+}
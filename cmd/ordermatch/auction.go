@@ -0,0 +1,129 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+)
+
+// imbalanceStatus returns the SecurityTradingStatus reporting an auction of phase with more
+// quantity on side, no side if it is balanced
+func imbalanceStatus(phase internal.Phase, side enum.Side) enum.SecurityTradingStatus {
+	if phase == internal.PhaseClosingAuction {
+		switch side {
+		case enum.Side_BUY:
+			return enum.SecurityTradingStatus_MARKET_ON_CLOSE_IMBALANCE_BUY
+		case enum.Side_SELL:
+			return enum.SecurityTradingStatus_MARKET_ON_CLOSE_IMBALANCE_SELL
+		}
+		return enum.SecurityTradingStatus_NO_MARKET_ON_CLOSE_IMBALANCE
+	}
+
+	switch side {
+	case enum.Side_BUY:
+		return enum.SecurityTradingStatus_MARKET_IMBALANCE_BUY
+	case enum.Side_SELL:
+		return enum.SecurityTradingStatus_MARKET_IMBALANCE_SELL
+	}
+	return enum.SecurityTradingStatus_NO_MARKET_IMBALANCE
+}
+
+// newIndicationStatus builds the SecurityStatus publishing where the auction of symbol would
+// uncross, for a session of FIX version beginString. LastPx carries the indicative price,
+// BuyVolume and SellVolume the quantities on either side of it.
+func newIndicationStatus(symbol string, phase internal.Phase, indication internal.Indication, beginString string) *quickfix.Message {
+	imbalance, side := indication.Imbalance()
+
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_SECURITY_STATUS))
+	msg.Body.Set(field.NewSymbol(symbol))
+	msg.Body.Set(field.NewTradingSessionID(tradingSessionID))
+	msg.Body.Set(field.NewUnsolicitedIndicator(true))
+	msg.Body.Set(field.NewSecurityTradingStatus(imbalanceStatus(phase, side)))
+	msg.Body.Set(field.NewBuyVolume(indication.BuyVolume, 2))
+	msg.Body.Set(field.NewSellVolume(indication.SellVolume, 2))
+	if indication.Crosses() {
+		msg.Body.Set(field.NewLastPx(indication.Price, 2))
+	}
+	msg.Body.Set(field.NewTransactTime(time.Now()))
+
+	// FIX 4.2 security status has no Text
+	if beginString == quickfix.BeginStringFIX42 {
+		return msg
+	}
+
+	text := "no indicative price"
+	if indication.Crosses() {
+		text = fmt.Sprintf("indicative price %v volume %v", indication.Price, indication.Volume)
+		if side != "" {
+			text += fmt.Sprintf(" imbalance %v %v", imbalance, sideName(side))
+		}
+	}
+	msg.Body.Set(field.NewText(text))
+
+	return msg
+}
+
+// sideName names the side of an imbalance
+func sideName(side enum.Side) string {
+	if side == enum.Side_BUY {
+		return "buy"
+	}
+
+	return "sell"
+}
+
+// publishIndication sends every logged on session where the call auction of symbol would
+// uncross, if symbol is in a call auction and the indication moved since it was last sent
+func (a *Application) publishIndication(symbol string) {
+	phase := a.phases.Of(symbol)
+	if !phase.Auction() {
+		return
+	}
+
+	indication, ok := a.Indicate(symbol)
+	if !ok {
+		return
+	}
+
+	if last, ok := a.indications[symbol]; ok && last.Equal(indication) {
+		return
+	}
+	a.indications[symbol] = indication
+
+	a.broadcast(func(beginString string) *quickfix.Message {
+		return newIndicationStatus(symbol, phase, indication, beginString)
+	})
+}
+
+// uncross ends the call auction of symbol, trading the crossing orders at the clearing price,
+// and reports the fills and the orders self-trade prevention canceled or reduced, as match does
+func (a *Application) uncross(symbol string) []internal.Trade {
+	trades, prevented, err := a.Uncross(symbol)
+	if err != nil {
+		a.stop(err)
+		return nil
+	}
+	a.reportMatch(trades, prevented)
+
+	return trades
+}
@@ -0,0 +1,55 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"fmt"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// cxlRejReason maps the reason the matcher refused a cancel or replace to its CxlRejReason,
+// BROKER when FIX has no reason for it
+func cxlRejReason(err error) enum.CxlRejReason {
+	switch err {
+	case internal.ErrUnknownOrder:
+		return enum.CxlRejReason_UNKNOWN_ORDER
+	case internal.ErrTooLateToCancel:
+		return enum.CxlRejReason_TOO_LATE_TO_CANCEL
+	case internal.ErrDuplicateClOrdID:
+		return enum.CxlRejReason_DUPLICATE_CLORDID
+	}
+
+	return enum.CxlRejReason_BROKER
+}
+
+// rejectCancelRequest answers req with an OrderCancelReject in the FIX version of sessionID.
+// order is the order the request referred to, nil if there is no such order.
+func (a *Application) rejectCancelRequest(req messages.CancelRequest, order *internal.Order, reason error, sessionID quickfix.SessionID) {
+	orderID, status := "NONE", enum.OrdStatus_REJECTED
+	if order != nil {
+		orderID, status = order.OrderID, statusOf(*order)
+	}
+
+	msg := messages.NewOrderCancelReject(req, orderID, status, cxlRejReason(reason), reason.Error(), sessionID.BeginString)
+	if err := quickfix.SendToTarget(msg, sessionID); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/tag"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix40er "github.com/quickfixgo/fix40/executionreport"
+	fix41er "github.com/quickfixgo/fix41/executionreport"
+	fix42er "github.com/quickfixgo/fix42/executionreport"
+	fix43er "github.com/quickfixgo/fix43/executionreport"
+	fix44er "github.com/quickfixgo/fix44/executionreport"
+	fix50er "github.com/quickfixgo/fix50/executionreport"
+)
+
+// sessionOf returns the session the order was entered on
+func sessionOf(order internal.Order) quickfix.SessionID {
+	return quickfix.SessionID{
+		BeginString:  order.BeginString,
+		SenderCompID: order.TargetCompID,
+		TargetCompID: order.SenderCompID,
+	}
+}
+
+// newExecutionReport builds an ExecutionReport for order in the FIX version of the session
+// that entered it, failing only if the execution ID of a report that is not a fill cannot be
+// journaled
+func (a *Application) newExecutionReport(order internal.Order, execTypeValue enum.ExecType, status enum.OrdStatus) (*quickfix.Message, error) {
+	orderID := field.NewOrderID(order.OrderID)
+	execType := field.NewExecType(execTypeValue)
+	ordStatus := field.NewOrdStatus(status)
+	symbol := field.NewSymbol(order.Symbol)
+	side := field.NewSide(order.Side)
+	leavesQty := field.NewLeavesQty(order.OpenQuantity(), 2)
+	cumQty := field.NewCumQty(order.ExecutedQuantity, 2)
+	avgPx := field.NewAvgPx(order.AvgPx, 2)
+
+	var isFill bool
+	switch execTypeValue {
+	case enum.ExecType_FILL, enum.ExecType_PARTIAL_FILL:
+		isFill = true
+	}
+
+	// a fill is reported under the execution ID the matcher recorded for it
+	var execID field.ExecIDField
+	if fill, ok := order.LastFill(); ok && isFill {
+		execID = field.NewExecID(fill.ExecID)
+	} else {
+		id, err := a.NextExecID()
+		if err != nil {
+			return nil, err
+		}
+		execID = field.NewExecID(id)
+	}
+
+	var msg *quickfix.Message
+	switch order.BeginString {
+	case quickfix.BeginStringFIX40:
+		msg = fix40er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), ordStatus, symbol, side,
+			field.NewOrderQty(order.Quantity, 2),
+			field.NewLastShares(order.LastExecutedQuantity, 2),
+			field.NewLastPx(order.LastExecutedPrice, 2),
+			cumQty, avgPx,
+		).ToMessage()
+
+	case quickfix.BeginStringFIX41:
+		msg = fix41er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), execType, ordStatus, symbol, side,
+			field.NewOrderQty(order.Quantity, 2),
+			field.NewLastShares(order.LastExecutedQuantity, 2),
+			field.NewLastPx(order.LastExecutedPrice, 2),
+			leavesQty, cumQty, avgPx,
+		).ToMessage()
+
+	case quickfix.BeginStringFIX42:
+		msg = fix42er.New(orderID, execID, field.NewExecTransType(enum.ExecTransType_NEW), execType, ordStatus, symbol, side, leavesQty, cumQty, avgPx).ToMessage()
+		if isFill {
+			msg.Body.Set(field.NewLastShares(order.LastExecutedQuantity, 2))
+			msg.Body.Set(field.NewLastPx(order.LastExecutedPrice, 2))
+		}
+
+	case quickfix.BeginStringFIX43:
+		msg = fix43er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty, avgPx).ToMessage()
+		if isFill {
+			msg.Body.Set(field.NewLastQty(order.LastExecutedQuantity, 2))
+			msg.Body.Set(field.NewLastPx(order.LastExecutedPrice, 2))
+		}
+
+	case quickfix.BeginStringFIX44:
+		if isFill {
+			execType = field.NewExecType(enum.ExecType_TRADE)
+		}
+		msg = fix44er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty, avgPx).ToMessage()
+		if isFill {
+			msg.Body.Set(field.NewLastQty(order.LastExecutedQuantity, 2))
+			msg.Body.Set(field.NewLastPx(order.LastExecutedPrice, 2))
+		}
+
+	default:
+		if isFill {
+			execType = field.NewExecType(enum.ExecType_TRADE)
+		}
+		msg = fix50er.New(orderID, execID, execType, ordStatus, side, leavesQty, cumQty).ToMessage()
+		msg.Body.Set(avgPx)
+		if isFill {
+			msg.Body.Set(field.NewLastQty(order.LastExecutedQuantity, 2))
+			msg.Body.Set(field.NewLastPx(order.LastExecutedPrice, 2))
+		}
+	}
+
+	msg.Body.Set(field.NewClOrdID(order.ClOrdID))
+	msg.Body.Set(symbol)
+	msg.Body.Set(field.NewOrderQty(order.Quantity, 2))
+
+	if fill, ok := order.LastFill(); ok && isFill {
+		setContraParty(msg, order.BeginString, fill)
+	}
+
+	// FIX 4.0 execution reports have no OrigClOrdID
+	switch execTypeValue {
+	case enum.ExecType_REPLACED, enum.ExecType_CANCELED:
+		if order.OrigClOrdID != "" && order.BeginString != quickfix.BeginStringFIX40 {
+			msg.Body.Set(field.NewOrigClOrdID(order.OrigClOrdID))
+		}
+	}
+
+	return msg, nil
+}
+
+var contraBrokers = quickfix.GroupTemplate{
+	quickfix.GroupElement(tag.ContraBroker),
+	quickfix.GroupElement(tag.ContraTrader),
+	quickfix.GroupElement(tag.ContraTradeQty),
+	quickfix.GroupElement(tag.ContraTradeTime),
+}
+
+// setContraParty adds who the fill traded against, and whether the order took or provided
+// liquidity, as far as the FIX version of the report allows. The matcher knows firms only
+// by SenderCompID, so the contra order stands in for the contra trader.
+func setContraParty(msg *quickfix.Message, beginString string, fill internal.Fill) {
+	switch beginString {
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41:
+		return
+	}
+
+	contra := quickfix.NewRepeatingGroup(tag.NoContraBrokers, contraBrokers)
+	entry := contra.Add()
+	entry.Set(field.NewContraBroker(fill.ContraBroker))
+	entry.Set(field.NewContraTrader(fill.ContraOrderID))
+	entry.Set(field.NewContraTradeQty(fill.Quantity, 2))
+	entry.Set(field.NewContraTradeTime(fill.Time))
+	msg.Body.SetGroup(contra)
+
+	switch beginString {
+	case quickfix.BeginStringFIX42, quickfix.BeginStringFIX43:
+		return
+	}
+
+	// an auction fill neither added nor removed liquidity
+	if !fill.Auction {
+		liquidity := enum.LastLiquidityInd_ADDED_LIQUIDITY
+		if fill.Aggressor {
+			liquidity = enum.LastLiquidityInd_REMOVED_LIQUIDITY
+		}
+		msg.Body.Set(field.NewLastLiquidityInd(liquidity))
+	}
+
+	if beginString == quickfix.BeginStringFIX44 {
+		return
+	}
+
+	msg.Body.Set(field.NewAggressorIndicator(fill.Aggressor))
+}
@@ -0,0 +1,197 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// Indication is where a call auction would uncross if the call ended now
+type Indication struct {
+	// Price is the clearing price, zero if no orders cross
+	Price decimal.Decimal
+
+	// Volume is the quantity that executes at Price
+	Volume decimal.Decimal
+
+	// BuyVolume and SellVolume are the quantities bid at or above Price and offered at or
+	// below it. The larger of the two exceeds Volume by the imbalance.
+	BuyVolume  decimal.Decimal
+	SellVolume decimal.Decimal
+}
+
+// Crosses returns true if the auction would trade
+func (i Indication) Crosses() bool {
+	return i.Volume.IsPositive()
+}
+
+// Imbalance returns the quantity left without a contra at Price and the side it is on, no
+// side if the auction is balanced
+func (i Indication) Imbalance() (decimal.Decimal, enum.Side) {
+	switch i.BuyVolume.Cmp(i.SellVolume) {
+	case 1:
+		return i.BuyVolume.Sub(i.SellVolume), enum.Side_BUY
+	case -1:
+		return i.SellVolume.Sub(i.BuyVolume), enum.Side_SELL
+	}
+
+	return decimal.Zero, ""
+}
+
+// Equal returns true if both indications show the same auction
+func (i Indication) Equal(other Indication) bool {
+	return i.Price.Equal(other.Price) && i.BuyVolume.Equal(other.BuyVolume) && i.SellVolume.Equal(other.SellVolume)
+}
+
+// executableAt returns true if order trades at an auction clearing price
+func executableAt(order *Order, price decimal.Decimal) bool {
+	switch {
+	case order.IsMarket():
+		return true
+	case order.Side == enum.Side_BUY:
+		return order.Price.GreaterThanOrEqual(price)
+	default:
+		return order.Price.LessThanOrEqual(price)
+	}
+}
+
+// volumeAt sums the open quantity on the side that trades at an auction clearing price.
+// Levels are visited best first, so the sum stops at the first level that does not trade.
+func (l orderList) volumeAt(price decimal.Decimal) decimal.Decimal {
+	volume := decimal.Zero
+	l.eachLevel(func(level *priceLevel) bool {
+		if !level.market && l.better(price, level.price) {
+			return false
+		}
+
+		for e := level.orders.Front(); e != nil; e = e.Next() {
+			volume = volume.Add(e.Value.(*Order).OpenQuantity())
+		}
+		return true
+	})
+
+	return volume
+}
+
+// Indicate returns where the book would uncross as a call auction. Of the limit prices in
+// the book, the clearing price is the one executing the most quantity, then leaving the
+// smallest imbalance, then lying closest to the reference price; a remaining tie goes to the
+// lower price.
+func (m Market) Indicate() Indication {
+	best := Indication{Price: decimal.Zero, Volume: decimal.Zero, BuyVolume: decimal.Zero, SellVolume: decimal.Zero}
+
+	reference, hasReference := m.reference()
+	distance := func(price decimal.Decimal) decimal.Decimal {
+		return price.Sub(reference).Abs()
+	}
+
+	for _, price := range m.limitPrices() {
+		candidate := Indication{Price: price, BuyVolume: m.Bids.volumeAt(price), SellVolume: m.Offers.volumeAt(price)}
+		candidate.Volume = decimal.Min(candidate.BuyVolume, candidate.SellVolume)
+		if !candidate.Crosses() {
+			continue
+		}
+
+		if !best.Crosses() {
+			best = candidate
+			continue
+		}
+
+		if c := candidate.Volume.Cmp(best.Volume); c != 0 {
+			if c > 0 {
+				best = candidate
+			}
+			continue
+		}
+
+		imbalance, _ := candidate.Imbalance()
+		bestImbalance, _ := best.Imbalance()
+		if c := imbalance.Cmp(bestImbalance); c != 0 {
+			if c < 0 {
+				best = candidate
+			}
+			continue
+		}
+
+		if hasReference && distance(price).LessThan(distance(best.Price)) {
+			best = candidate
+		}
+	}
+
+	return best
+}
+
+// limitPrices returns the distinct limit prices of both sides of the book, lowest first
+func (m Market) limitPrices() (prices []decimal.Decimal) {
+	add := func(price decimal.Decimal) {
+		i := len(prices)
+		for i > 0 && prices[i-1].GreaterThan(price) {
+			i--
+		}
+		if i > 0 && prices[i-1].Equal(price) {
+			return
+		}
+
+		prices = append(prices, decimal.Zero)
+		copy(prices[i+1:], prices[i:])
+		prices[i] = price
+	}
+
+	for _, list := range []orderList{m.Bids, m.Offers} {
+		for _, level := range list.prices {
+			if !level.market {
+				add(level.price)
+			}
+		}
+	}
+
+	return
+}
+
+// Uncross ends a call auction, executing every order that trades at the clearing price
+// Indicate returns at that one price, best priced and oldest orders first. Crossing orders
+// of the same firm are kept from trading as stp says, which may leave less than the
+// indicated volume to execute.
+func (m *Market) Uncross(stp SelfTradePrevention) (trades []Trade, prevented []Prevention) {
+	indication := m.Indicate()
+	if !indication.Crosses() {
+		return
+	}
+	price := indication.Price
+
+	for m.Bids.Len() > 0 && m.Offers.Len() > 0 {
+		bid, offer := m.Bids.Best(), m.Offers.Best()
+		if !executableAt(bid, price) || !executableAt(offer, price) {
+			break
+		}
+
+		if stp != STPOff && bid.SenderCompID == offer.SenderCompID {
+			// the later arrival takes the part of the aggressor
+			aggressor, resting := bid, offer
+			if offer.arrival > bid.arrival {
+				aggressor, resting = offer, bid
+			}
+
+			prevented = append(prevented, m.preventSelfTrade(stp, aggressor, resting)...)
+			continue
+		}
+
+		trades = append(trades, m.fill(bid, offer, price, true))
+	}
+
+	return
+}
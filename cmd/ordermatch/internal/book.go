@@ -0,0 +1,165 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"container/list"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// priceLevel is the queue of orders resting at one price, oldest first
+type priceLevel struct {
+	price decimal.Decimal
+
+	// market is true for the level holding market orders, which ranks ahead of every price
+	market bool
+
+	orders *list.List
+}
+
+// queued is the place of an order in the book
+type queued struct {
+	level   *priceLevel
+	element *list.Element
+}
+
+// orderList is one side of the book. Its price levels are kept worst first, so the best level
+// is last, where levels come and go most often at the least cost. An index from the firm and
+// ClOrdID to the place of each order lets it be found and removed without a scan.
+type orderList struct {
+	prices []*priceLevel
+	index  map[orderKey]queued
+
+	// better returns true if price p1 ranks ahead of price p2 on this side
+	better func(p1, p2 decimal.Decimal) bool
+}
+
+func newOrderList(better func(p1, p2 decimal.Decimal) bool) orderList {
+	return orderList{index: make(map[orderKey]queued), better: better}
+}
+
+func bids() orderList {
+	return newOrderList(decimal.Decimal.GreaterThan)
+}
+
+func offers() orderList {
+	return newOrderList(decimal.Decimal.LessThan)
+}
+
+func (l orderList) Len() int { return len(l.index) }
+
+// search returns the position of the level order belongs to, and true if the level exists
+func (l orderList) search(order *Order) (int, bool) {
+	n := len(l.prices)
+	if order.IsMarket() {
+		if n > 0 && l.prices[n-1].market {
+			return n - 1, true
+		}
+		return n, false
+	}
+
+	i := sort.Search(n, func(i int) bool {
+		level := l.prices[i]
+		return level.market || !l.better(order.Price, level.price)
+	})
+
+	return i, i < n && !l.prices[i].market && l.prices[i].price.Equal(order.Price)
+}
+
+// Insert queues order behind every order already resting at its price
+func (l *orderList) Insert(order *Order) {
+	i, ok := l.search(order)
+	if !ok {
+		level := &priceLevel{price: order.Price, market: order.IsMarket(), orders: list.New()}
+		l.prices = append(l.prices, nil)
+		copy(l.prices[i+1:], l.prices[i:])
+		l.prices[i] = level
+	}
+
+	level := l.prices[i]
+	l.index[order.key()] = queued{level: level, element: level.orders.PushBack(order)}
+}
+
+func (l orderList) Find(key orderKey) *Order {
+	q, ok := l.index[key]
+	if !ok {
+		return nil
+	}
+
+	return q.element.Value.(*Order)
+}
+
+func (l *orderList) Remove(key orderKey) *Order {
+	q, ok := l.index[key]
+	if !ok {
+		return nil
+	}
+
+	delete(l.index, key)
+	order := q.level.orders.Remove(q.element).(*Order)
+	if q.level.orders.Len() == 0 {
+		i, _ := l.search(order)
+		copy(l.prices[i:], l.prices[i+1:])
+		l.prices[len(l.prices)-1] = nil
+		l.prices = l.prices[:len(l.prices)-1]
+	}
+
+	return order
+}
+
+// rename moves the index entry of an order from orig to its current ClOrdID, leaving its
+// place in the queue untouched
+func (l *orderList) rename(orig orderKey, order *Order) {
+	q, ok := l.index[orig]
+	if !ok {
+		return
+	}
+
+	delete(l.index, orig)
+	l.index[order.key()] = q
+}
+
+// Best returns the order at the front of the best level, nil if the side is empty
+func (l orderList) Best() *Order {
+	if len(l.prices) == 0 {
+		return nil
+	}
+
+	return l.prices[len(l.prices)-1].orders.Front().Value.(*Order)
+}
+
+// eachLevel calls f with every price level, best first, until f returns false
+func (l orderList) eachLevel(f func(*priceLevel) bool) {
+	for i := len(l.prices) - 1; i >= 0; i-- {
+		if !f(l.prices[i]) {
+			return
+		}
+	}
+}
+
+// Each calls f with every order in priority order until f returns false
+func (l orderList) Each(f func(*Order) bool) {
+	l.eachLevel(func(level *priceLevel) bool {
+		for e := level.orders.Front(); e != nil; e = e.Next() {
+			if !f(e.Value.(*Order)) {
+				return false
+			}
+		}
+		return true
+	})
+}
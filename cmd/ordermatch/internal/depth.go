@@ -0,0 +1,84 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// PriceLevel is the aggregated open quantity resting at a single price
+type PriceLevel struct {
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+	Orders   int
+}
+
+// LevelUpdate describes how a price level changed between two views of the book
+type LevelUpdate struct {
+	Action enum.MDUpdateAction
+	PriceLevel
+}
+
+func (l orderList) levels(depth int) (levels []PriceLevel) {
+	l.eachLevel(func(level *priceLevel) bool {
+		if depth > 0 && len(levels) == depth {
+			return false
+		}
+
+		aggregated := PriceLevel{Price: level.price, Quantity: decimal.Zero, Orders: level.orders.Len()}
+		for e := level.orders.Front(); e != nil; e = e.Next() {
+			aggregated.Quantity = aggregated.Quantity.Add(e.Value.(*Order).OpenQuantity())
+		}
+		levels = append(levels, aggregated)
+		return true
+	})
+
+	return
+}
+
+// DiffLevels returns the updates that turn the before levels into the after levels.
+// Levels are matched by price; deletes are listed first so a consumer applying
+// the updates in order never holds more levels than the subscribed depth.
+func DiffLevels(before, after []PriceLevel) (updates []LevelUpdate) {
+	for _, old := range before {
+		if _, ok := findLevel(after, old.Price); !ok {
+			updates = append(updates, LevelUpdate{enum.MDUpdateAction_DELETE, old})
+		}
+	}
+
+	for _, level := range after {
+		old, ok := findLevel(before, level.Price)
+		switch {
+		case !ok:
+			updates = append(updates, LevelUpdate{enum.MDUpdateAction_NEW, level})
+		case !old.Quantity.Equal(level.Quantity) || old.Orders != level.Orders:
+			updates = append(updates, LevelUpdate{enum.MDUpdateAction_CHANGE, level})
+		}
+	}
+
+	return
+}
+
+func findLevel(levels []PriceLevel, price decimal.Decimal) (PriceLevel, bool) {
+	for _, level := range levels {
+		if level.Price.Equal(price) {
+			return level, true
+		}
+	}
+
+	return PriceLevel{}, false
+}
@@ -0,0 +1,145 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// EventType identifies the change to the book an Event records
+type EventType string
+
+const (
+	EventInsert  EventType = "insert"
+	EventCancel  EventType = "cancel"
+	EventReplace EventType = "replace"
+	EventFill    EventType = "fill"
+
+	// EventRestate records the quantity of an order reduced by self-trade prevention
+	EventRestate EventType = "restate"
+
+	// EventExecID records an execution ID handed out for a report that is not a fill, so
+	// IDs are not reused after a restart
+	EventExecID EventType = "execID"
+
+	// EventTrade records a trade, so the trade history survives a restart
+	EventTrade EventType = "trade"
+
+	// EventOrderID records an order ID handed out, so IDs are not reused after a restart
+	EventOrderID EventType = "orderID"
+
+	// EventClOrdID records a ClOrdID a firm used on a trade date, so it is not used again that
+	// day after a restart
+	EventClOrdID EventType = "clOrdID"
+)
+
+// Event is a single entry in the journal
+type Event struct {
+	Type         EventType        `json:"type"`
+	Symbol       string           `json:"symbol,omitempty"`
+	Side         enum.Side        `json:"side,omitempty"`
+	SenderCompID string           `json:"senderCompID,omitempty"`
+	ClOrdID      string           `json:"clOrdID,omitempty"`
+	OrigClOrdID  string           `json:"origClOrdID,omitempty"`
+	Price        *decimal.Decimal `json:"price,omitempty"`
+	Quantity     *decimal.Decimal `json:"quantity,omitempty"`
+	Order        *Order           `json:"order,omitempty"`
+	Fill         *Fill            `json:"fill,omitempty"`
+	Trade        *Trade           `json:"trade,omitempty"`
+	ExecID       string           `json:"execID,omitempty"`
+	OrderID      string           `json:"orderID,omitempty"`
+	TradeDate    string           `json:"tradeDate,omitempty"`
+}
+
+// JournalError is an event the journal could not record. After the first one the journal is
+// not written again, as it may end in a partial record, so every later event fails as well.
+type JournalError struct {
+	Type EventType
+	Err  error
+}
+
+func (e JournalError) Error() string {
+	return fmt.Sprintf("unable to journal %v event: %s", e.Type, e.Err)
+}
+
+// Journal is an append only file of events, one JSON object per line. Each event is
+// synced to disk before the change it records is reported to anyone.
+type Journal struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// OpenJournal opens the journal at path, creating it if needed, and returns the events
+// already in it. A record left incomplete by a crash is dropped.
+func OpenJournal(path string) (*Journal, []Event, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var events []Event
+	decoder := json.NewDecoder(file)
+	for {
+		offset := decoder.InputOffset()
+
+		var event Event
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return nil, nil, err
+			}
+			break
+		}
+
+		events = append(events, event)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return &Journal{file: file, encoder: json.NewEncoder(file)}, events, nil
+}
+
+// Record appends event to the journal and syncs it to disk
+func (j *Journal) Record(event Event) error {
+	if err := j.encoder.Encode(event); err != nil {
+		return err
+	}
+
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
@@ -16,80 +16,73 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
-	"sort"
 	"time"
 
 	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
 )
 
-type orderList struct {
-	orders []*Order
-	sortBy func(o1, o2 *Order) bool
-}
+var (
+	// ErrUnknownOrder is returned when the order is not resting in the book
+	ErrUnknownOrder = errors.New("unknown order")
 
-func (l orderList) Len() int { return len(l.orders) }
-func (l orderList) Swap(i, j int) {
-	l.orders[i], l.orders[j] = l.orders[j], l.orders[i]
-}
-func (l orderList) Less(i, j int) bool { return l.sortBy(l.orders[i], l.orders[j]) }
+	// ErrTooLateToCancel is returned when the order has already been filled or canceled
+	ErrTooLateToCancel = errors.New("too late to cancel")
 
-func (l *orderList) Insert(order *Order) {
-	l.orders = append(l.orders, order)
-	sort.Sort(l)
-}
+	// ErrQuantityBelowExecuted is returned when a replace would reduce the order quantity below what has already executed
+	ErrQuantityBelowExecuted = errors.New("order quantity below executed quantity")
 
-func (l *orderList) Remove(clordID string) (order *Order) {
-	for i := 0; i < len(l.orders); i++ {
-		if l.orders[i].ClOrdID == clordID {
-			order = l.orders[i]
-			l.orders = append(l.orders[:i], l.orders[i+1:]...)
-			return
-		}
-	}
+	// ErrInvalidQuantity is returned for an order quantity that is not positive
+	ErrInvalidQuantity = errors.New("order quantity must be positive")
 
-	return
-}
+	// ErrDuplicateClOrdID is returned when the firm has already used the ClOrdID for an order
+	ErrDuplicateClOrdID = errors.New("duplicate ClOrdID")
+)
 
-func bids() (b orderList) {
-	b.sortBy = func(i, j *Order) bool {
-		switch i.Price.Cmp(j.Price) {
-		case 1:
-			return true
-		case -1:
-			return false
-		}
+// closedOrdersKept is how many orders that have left the book each market remembers, the
+// oldest are forgotten first
+const closedOrdersKept = 100000
 
-		return i.insertTime.Before(j.insertTime)
+// crosses returns true if bid and offer can trade with each other
+func crosses(bid, offer *Order) bool {
+	if bid.IsMarket() || offer.IsMarket() {
+		return true
 	}
 
-	return
-}
-
-func offers() (o orderList) {
-	o.sortBy = func(i, j *Order) bool {
-		switch i.Price.Cmp(j.Price) {
-		case 1:
-			return false
-		case -1:
-			return true
-		}
-
-		return i.insertTime.Before(j.insertTime)
-	}
-
-	return
+	return bid.Price.GreaterThanOrEqual(offer.Price)
 }
 
 // Market is a simple CLOB
 type Market struct {
 	Bids   orderList
 	Offers orderList
+
+	// orders that have left the book, filled or canceled, and their keys oldest first. Only the
+	// last closedOrdersKept are remembered.
+	closed     map[orderKey]*Order
+	closedKeys []orderKey
+
+	// every order resting in the book or remembered in closed, by OrderID
+	byOrderID map[string]*Order
+
+	execIDs *sequence
+
+	// counts orders entering the queue, the later arrival of two crossing orders is the aggressor
+	arrivals uint64
+
+	// price of the most recent trade, zero before the first one
+	lastPrice decimal.Decimal
+
+	// what each firm has at stake, kept up to date by every change to its orders
+	exposures map[string]*exposure
 }
 
 // NewMarket returns an initialized Market instance
 func NewMarket() *Market {
-	return &Market{bids(), offers()}
+	return &Market{Bids: bids(), Offers: offers(), closed: make(map[orderKey]*Order), byOrderID: make(map[string]*Order),
+		execIDs: new(sequence), exposures: make(map[string]*exposure)}
 }
 
 func (m Market) Display() {
@@ -97,65 +90,343 @@ func (m Market) Display() {
 	fmt.Println("-----")
 	fmt.Println()
 
-	for _, bid := range m.Bids.orders {
+	m.Bids.Each(func(bid *Order) bool {
 		fmt.Printf("%+v\n", bid)
-	}
+		return true
+	})
 
 	fmt.Println("OFFERS:")
 	fmt.Println("-----")
 	fmt.Println()
 
-	for _, offer := range m.Offers.orders {
+	m.Offers.Each(func(offer *Order) bool {
 		fmt.Printf("%+v\n", offer)
+		return true
+	})
+}
+
+// Depth returns the aggregated price levels on each side of the book, best price
+// first. A depth of zero returns every level.
+func (m Market) Depth(depth int) (bids, offers []PriceLevel) {
+	return m.Bids.levels(depth), m.Offers.levels(depth)
+}
+
+// Orders returns the orders resting on each side of the book, in priority order
+func (m Market) Orders() (bids, offers []*Order) {
+	collect := func(orders *[]*Order) func(*Order) bool {
+		return func(order *Order) bool {
+			*orders = append(*orders, order)
+			return true
+		}
 	}
+
+	m.Bids.Each(collect(&bids))
+	m.Offers.Each(collect(&offers))
+	return
 }
 
-func (m *Market) Insert(order Order) {
+// arrive stamps order as the newest in the queue at its price
+func (m *Market) arrive(order *Order) {
+	m.arrivals++
+	order.arrival = m.arrivals
 	order.insertTime = time.Now()
+}
+
+func (m *Market) Insert(order Order) {
+	m.arrive(&order)
+	m.exposureOf(order.SenderCompID).add(&order, 1)
+	if order.OrderID != "" {
+		m.byOrderID[order.OrderID] = &order
+	}
 	if order.Side == enum.Side_BUY {
 		m.Bids.Insert(&order)
 	} else {
 		m.Offers.Insert(&order)
 	}
 }
-func (m *Market) Cancel(clordID string, side enum.Side) (order *Order) {
+
+// Find returns the resting order clordID of senderCompID, or the order as it left the book if
+// it has been filled or canceled, nil if there is no such order
+func (m Market) Find(senderCompID, clordID string, side enum.Side) *Order {
+	list := m.Offers
+	if side == enum.Side_BUY {
+		list = m.Bids
+	}
+
+	key := orderKey{senderCompID: senderCompID, clOrdID: clordID}
+	if order := list.Find(key); order != nil {
+		return order
+	}
+
+	return m.closed[key]
+}
+
+// Order returns the order with orderID on either side of the book, or as it left the book,
+// nil if there is no such order
+func (m Market) Order(orderID string) *Order {
+	return m.byOrderID[orderID]
+}
+
+// notResting returns why the order with key is not in the book
+func (m Market) notResting(key orderKey) error {
+	if _, ok := m.closed[key]; ok {
+		return ErrTooLateToCancel
+	}
+
+	return ErrUnknownOrder
+}
+
+// close remembers order as having left the book, forgetting the oldest closed order once
+// closedOrdersKept are remembered
+func (m *Market) close(order *Order) {
+	key := order.key()
+	if _, ok := m.closed[key]; !ok {
+		m.closedKeys = append(m.closedKeys, key)
+	}
+	m.closed[key] = order
+
+	for len(m.closedKeys) > closedOrdersKept {
+		oldest := m.closedKeys[0]
+		m.closedKeys[0] = orderKey{}
+		m.closedKeys = m.closedKeys[1:]
+
+		if forgotten, ok := m.closed[oldest]; ok {
+			delete(m.closed, oldest)
+			delete(m.byOrderID, forgotten.OrderID)
+		}
+	}
+}
+
+func (m *Market) Cancel(senderCompID, clordID string, side enum.Side) (*Order, error) {
+	key := orderKey{senderCompID: senderCompID, clOrdID: clordID}
+
+	var order *Order
 	if side == enum.Side_BUY {
-		order = m.Bids.Remove(clordID)
+		order = m.Bids.Remove(key)
 	} else {
-		order = m.Offers.Remove(clordID)
+		order = m.Offers.Remove(key)
 	}
 
-	if order != nil {
-		order.Cancel()
+	if order == nil {
+		return nil, m.notResting(key)
 	}
 
-	return
+	m.change(order, order.Cancel)
+	m.close(order)
+
+	return order, nil
 }
 
-func (m *Market) Match() (matched []Order) {
-	for m.Bids.Len() > 0 && m.Offers.Len() > 0 {
-		bestBid := m.Bids.orders[0]
-		bestOffer := m.Offers.orders[0]
+// Replace amends the price and quantity of the resting order origClOrdID of senderCompID,
+// giving it clOrdID. The order
+// keeps its time priority when only its quantity is reduced, and goes to the back of
+// the queue on a price change or a quantity increase.
+func (m *Market) Replace(senderCompID, origClOrdID string, side enum.Side, clOrdID string, price, quantity decimal.Decimal) (*Order, error) {
+	list := &m.Offers
+	if side == enum.Side_BUY {
+		list = &m.Bids
+	}
+
+	orig := orderKey{senderCompID: senderCompID, clOrdID: origClOrdID}
+	order := list.Find(orig)
+	if order == nil {
+		return nil, m.notResting(orig)
+	}
+
+	if quantity.LessThan(order.ExecutedQuantity) {
+		return nil, ErrQuantityBelowExecuted
+	}
+
+	// an order losing its priority leaves the book and queues again at its new price
+	losesPriority := !price.Equal(order.Price) || quantity.GreaterThan(order.Quantity)
+	if losesPriority {
+		list.Remove(orig)
+	}
+
+	order.OrigClOrdID = order.ClOrdID
+	order.ClOrdID = clOrdID
+	order.Price = price
+	m.change(order, func() { order.Quantity = quantity })
+
+	switch {
+	case losesPriority:
+		m.arrive(order)
+		list.Insert(order)
+	case order.IsClosed():
+		list.Remove(orig)
+		m.close(order)
+	default:
+		list.rename(orig, order)
+	}
+
+	return order, nil
+}
+
+// apply executes a fill recorded in the journal against the resting order with key
+func (m *Market) apply(key orderKey, side enum.Side, fill Fill) {
+	list := &m.Offers
+	if side == enum.Side_BUY {
+		list = &m.Bids
+	}
 
-		price := bestOffer.Price
-		quantity := bestBid.OpenQuantity()
-		if offerQuant := bestOffer.OpenQuantity(); offerQuant.Cmp(quantity) == -1 {
-			quantity = offerQuant
+	order := list.Find(key)
+	if order == nil {
+		return
+	}
+
+	m.change(order, func() { order.Execute(fill) })
+	m.lastPrice = fill.Price
+	if order.IsClosed() {
+		list.Remove(key)
+		m.close(order)
+	}
+}
+
+// reference returns the price of the last trade, or the mid of the best bid and offer before
+// the first trade, false if there is neither
+func (m Market) reference() (decimal.Decimal, bool) {
+	if m.lastPrice.IsPositive() {
+		return m.lastPrice, true
+	}
+
+	if m.Bids.Len() == 0 || m.Offers.Len() == 0 {
+		return decimal.Zero, false
+	}
+
+	bid, offer := m.Bids.Best(), m.Offers.Best()
+	if bid.IsMarket() || offer.IsMarket() {
+		return decimal.Zero, false
+	}
+
+	return bid.Price.Add(offer.Price).Div(decimal.NewFromInt(2)), true
+}
+
+// exposure returns what senderCompID has at stake in the market
+func (m Market) exposure(senderCompID string) exposure {
+	if e, ok := m.exposures[senderCompID]; ok {
+		return *e
+	}
+
+	return exposure{}
+}
+
+// exposureOf returns the exposure of senderCompID to keep up to date
+func (m *Market) exposureOf(senderCompID string) *exposure {
+	e, ok := m.exposures[senderCompID]
+	if !ok {
+		e = new(exposure)
+		m.exposures[senderCompID] = e
+	}
+
+	return e
+}
+
+// change applies f to order, keeping the exposure of its firm up to date
+func (m *Market) change(order *Order, f func()) {
+	e := m.exposureOf(order.SenderCompID)
+	e.add(order, -1)
+	f()
+	e.add(order, 1)
+}
+
+// CanFill returns true if the opposite side of the book holds enough quantity at prices the
+// order accepts to fill it entirely. Under stp the orders of its own firm do not count: with
+// STPCancelResting they are canceled as order reaches them, any other mode takes quantity off
+// order there, so it cannot fill past them.
+func (m Market) CanFill(order Order, stp SelfTradePrevention) bool {
+	contra, buy := m.Bids, false
+	if order.Side == enum.Side_BUY {
+		contra, buy = m.Offers, true
+	}
+
+	available, filled := decimal.Zero, false
+	contra.Each(func(resting *Order) bool {
+		if buy && !crosses(&order, resting) || !buy && !crosses(resting, &order) {
+			return false
+		}
+
+		if stp != STPOff && resting.SenderCompID == order.SenderCompID {
+			return stp == STPCancelResting
 		}
 
-		bestBid.Execute(price, quantity)
-		bestOffer.Execute(price, quantity)
+		available = available.Add(resting.OpenQuantity())
+		filled = available.GreaterThanOrEqual(order.OpenQuantity())
+		return !filled
+	})
+
+	return filled
+}
+
+// Match trades the crossing orders in the book. Each trade is priced at the resting
+// order's price; the order that arrived later is the aggressor. Crossing orders of the
+// same firm are kept from trading as stp says.
+func (m *Market) Match(stp SelfTradePrevention) (trades []Trade, prevented []Prevention) {
+	for m.Bids.Len() > 0 && m.Offers.Len() > 0 {
+		bestBid := m.Bids.Best()
+		bestOffer := m.Offers.Best()
+
+		if !crosses(bestBid, bestOffer) {
+			break
+		}
 
-		matched = append(matched, *bestBid, *bestOffer)
+		aggressor, resting := bestBid, bestOffer
+		if bestOffer.arrival > bestBid.arrival {
+			aggressor, resting = bestOffer, bestBid
+		}
 
-		if bestBid.IsClosed() {
-			m.Bids.orders = m.Bids.orders[1:]
+		if stp != STPOff && aggressor.SenderCompID == resting.SenderCompID {
+			prevented = append(prevented, m.preventSelfTrade(stp, aggressor, resting)...)
+			continue
 		}
 
-		if bestOffer.IsClosed() {
-			m.Offers.orders = m.Offers.orders[1:]
+		// market orders never rest, so two of them crossing leaves no price to trade at
+		if resting.IsMarket() {
+			break
 		}
+		price := resting.Price
+
+		trades = append(trades, m.fill(aggressor, resting, price, false))
 	}
 
 	return
 }
+
+// fill trades the open quantity aggressor and resting have in common at price, and takes the
+// orders it closes out of the book. An auction fill has no aggressor.
+func (m *Market) fill(aggressor, resting *Order, price decimal.Decimal, auction bool) Trade {
+	quantity := decimal.Min(aggressor.OpenQuantity(), resting.OpenQuantity())
+
+	now := time.Now()
+	m.change(aggressor, func() {
+		aggressor.Execute(Fill{ExecID: m.execIDs.next(), Price: price, Quantity: quantity,
+			ContraOrderID: resting.OrderID, ContraBroker: resting.SenderCompID, Time: now, Aggressor: !auction, Auction: auction})
+	})
+	m.change(resting, func() {
+		resting.Execute(Fill{ExecID: m.execIDs.next(), Price: price, Quantity: quantity,
+			ContraOrderID: aggressor.OrderID, ContraBroker: aggressor.SenderCompID, Time: now, Auction: auction})
+	})
+
+	m.lastPrice = price
+
+	trade := Trade{
+		Symbol:    aggressor.Symbol,
+		Price:     price,
+		Quantity:  quantity,
+		Aggressor: *aggressor,
+		Resting:   *resting,
+		Auction:   auction,
+		Time:      now,
+	}
+	if !auction {
+		trade.AggressorSide = aggressor.Side
+	}
+
+	for _, order := range []*Order{aggressor, resting} {
+		if order.IsClosed() {
+			m.list(order.Side).Remove(order.key())
+			m.close(order)
+		}
+	}
+
+	return trade
+}
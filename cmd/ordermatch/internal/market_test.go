@@ -0,0 +1,150 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+)
+
+func TestCanFillSelfTrade(t *testing.T) {
+	m := NewMarket()
+	m.Insert(testOrder("OWN", "1", "ABC", enum.Side_SELL, 100, 10))
+	m.Insert(testOrder("OTHER", "2", "ABC", enum.Side_SELL, 101, 10))
+	m.Insert(testOrder("OTHER", "3", "ABC", enum.Side_SELL, 102, 10))
+
+	tests := []struct {
+		stp      SelfTradePrevention
+		quantity int64
+		want     bool
+	}{
+		{STPOff, 30, true},
+		{STPCancelResting, 20, true},
+		{STPCancelResting, 30, false},
+		{STPCancelAggressor, 10, false},
+		{STPCancelBoth, 10, false},
+		{STPDecrement, 10, false},
+	}
+
+	for _, test := range tests {
+		order := testOrder("OWN", "4", "ABC", enum.Side_BUY, 102, test.quantity)
+		if got := m.CanFill(order, test.stp); got != test.want {
+			t.Errorf("CanFill of %v under %q: got %v, want %v", test.quantity, test.stp, got, test.want)
+		}
+	}
+}
+
+// TestMatchZeroQuantity crosses orders of no quantity with a resting order, which must trade
+// nothing rather than divide by the executed quantity
+func TestMatchZeroQuantity(t *testing.T) {
+	tests := []struct {
+		aggressor, resting int64
+	}{
+		{0, 10},
+		{10, 0},
+		{0, 0},
+	}
+
+	for _, test := range tests {
+		m := NewMarket()
+		m.Insert(testOrder("SELLER", "1", "ABC", enum.Side_SELL, 100, test.resting))
+		m.Insert(testOrder("BUYER", "2", "ABC", enum.Side_BUY, 100, test.aggressor))
+
+		trades, _ := m.Match(STPOff)
+		for _, trade := range trades {
+			if !trade.Quantity.IsZero() {
+				t.Errorf("%v against %v: traded %v", test.aggressor, test.resting, trade.Quantity)
+			}
+			if !trade.Aggressor.AvgPx.IsZero() || !trade.Resting.AvgPx.IsZero() {
+				t.Errorf("%v against %v: AvgPx %v and %v, want 0", test.aggressor, test.resting, trade.Aggressor.AvgPx, trade.Resting.AvgPx)
+			}
+		}
+	}
+}
+
+// benchOrders is how many orders rest in the books the benchmarks run against
+const benchOrders = 100000
+
+// benchLevels is how many prices the resting orders of each side are spread over
+const benchLevels = 1000
+
+// restingBook returns a market holding n orders, half of them bids below the other half
+func restingBook(n int) *Market {
+	m := NewMarket()
+	for i := 0; i < n/2; i++ {
+		m.Insert(testOrder("REST", fmt.Sprint("b", i), "ABC", enum.Side_BUY, int64(1000+i%benchLevels), 10))
+		m.Insert(testOrder("REST", fmt.Sprint("s", i), "ABC", enum.Side_SELL, int64(3000+i%benchLevels), 10))
+	}
+
+	return m
+}
+
+// newOrders returns n orders of one firm, priced by price
+func newOrders(n int, side enum.Side, price func(i int) int64) []Order {
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = testOrder("BENCH", fmt.Sprint(i), "ABC", side, price(i), 1)
+		orders[i].OrderID = fmt.Sprint(i)
+	}
+
+	return orders
+}
+
+func BenchmarkInsert(b *testing.B) {
+	m := restingBook(benchOrders)
+	orders := newOrders(b.N, enum.Side_BUY, func(i int) int64 { return int64(1000 + i%benchLevels) })
+
+	b.ResetTimer()
+	for i := range orders {
+		m.Insert(orders[i])
+	}
+}
+
+func BenchmarkCancel(b *testing.B) {
+	m := restingBook(benchOrders)
+	orders := newOrders(b.N, enum.Side_BUY, func(i int) int64 { return int64(1000 + i%benchLevels) })
+	for i := range orders {
+		m.Insert(orders[i])
+	}
+
+	b.ResetTimer()
+	for i := range orders {
+		if _, err := m.Cancel(orders[i].SenderCompID, orders[i].ClOrdID, orders[i].Side); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMatch times an incoming bid trading with the best offer, one trade per operation
+// each emptying the best price level
+func BenchmarkMatch(b *testing.B) {
+	m := restingBook(benchOrders)
+	for i, offer := range newOrders(b.N, enum.Side_SELL, func(i int) int64 { return int64(2000 + i) }) {
+		offer.ClOrdID = fmt.Sprint("o", i)
+		m.Insert(offer)
+	}
+	bids := newOrders(b.N, enum.Side_BUY, func(int) int64 { return 1 << 40 })
+
+	b.ResetTimer()
+	for i := range bids {
+		m.Insert(bids[i])
+		if trades, _ := m.Match(STPOff); len(trades) != 1 {
+			b.Fatalf("bid %v made %v trades", i, len(trades))
+		}
+	}
+}
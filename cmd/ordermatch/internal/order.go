@@ -22,27 +22,85 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+// Fill is a single execution against an order
+type Fill struct {
+	ExecID        string
+	Price         decimal.Decimal
+	Quantity      decimal.Decimal
+	ContraOrderID string
+	ContraBroker  string
+	Time          time.Time
+
+	// Aggressor is true when the order took liquidity on this fill, false when it was resting
+	Aggressor bool
+
+	// Auction is true when the fill came from the uncross of a call auction
+	Auction bool
+}
+
+// orderKey identifies an order by the firm that entered it and its ClOrdID, which is only
+// unique within the firm
+type orderKey struct {
+	senderCompID string
+	clOrdID      string
+}
+
 type Order struct {
+	// OrderID is assigned by the matcher, unique for the life of the journal
+	OrderID              string
 	ClOrdID              string
+	OrigClOrdID          string
 	Symbol               string
+	BeginString          string
 	SenderCompID         string
 	TargetCompID         string
 	Side                 enum.Side
 	OrdType              enum.OrdType
+	TimeInForce          enum.TimeInForce
 	Price                decimal.Decimal
 	Quantity             decimal.Decimal
 	ExecutedQuantity     decimal.Decimal
 	openQuantity         *decimal.Decimal
 	AvgPx                decimal.Decimal
 	insertTime           time.Time
+	arrival              uint64
 	LastExecutedQuantity decimal.Decimal
 	LastExecutedPrice    decimal.Decimal
+	Fills                []Fill
+
+	// sum of price times quantity over all fills, AvgPx is derived from it
+	notional decimal.Decimal
+}
+
+func (o Order) key() orderKey {
+	return orderKey{senderCompID: o.SenderCompID, clOrdID: o.ClOrdID}
+}
+
+// IsMarket returns true for a market order, which has no price and trades at any price
+func (o Order) IsMarket() bool {
+	return o.OrdType == enum.OrdType_MARKET
+}
+
+// IsImmediate returns true if whatever is left of the order after matching is canceled
+// rather than left resting in the book
+func (o Order) IsImmediate() bool {
+	switch o.TimeInForce {
+	case enum.TimeInForce_IMMEDIATE_OR_CANCEL, enum.TimeInForce_FILL_OR_KILL:
+		return true
+	}
+
+	return o.IsMarket()
 }
 
 func (o Order) IsClosed() bool {
 	return o.OpenQuantity().Equal(decimal.Zero)
 }
 
+// IsCanceled returns true if the remaining quantity of the order was canceled
+func (o Order) IsCanceled() bool {
+	return o.openQuantity != nil
+}
+
 func (o Order) OpenQuantity() decimal.Decimal {
 	if o.openQuantity == nil {
 		return o.Quantity.Sub(o.ExecutedQuantity)
@@ -51,10 +109,26 @@ func (o Order) OpenQuantity() decimal.Decimal {
 	return *o.openQuantity
 }
 
-func (o *Order) Execute(price, quantity decimal.Decimal) {
-	o.ExecutedQuantity = o.ExecutedQuantity.Add(quantity)
-	o.LastExecutedPrice = price
-	o.LastExecutedQuantity = quantity
+// LastFill returns the most recent fill of the order, false if the order has no fills
+func (o Order) LastFill() (Fill, bool) {
+	if len(o.Fills) == 0 {
+		return Fill{}, false
+	}
+
+	return o.Fills[len(o.Fills)-1], true
+}
+
+// Execute applies fill to the order, updating the executed quantity and the volume
+// weighted average price
+func (o *Order) Execute(fill Fill) {
+	o.ExecutedQuantity = o.ExecutedQuantity.Add(fill.Quantity)
+	o.notional = o.notional.Add(fill.Price.Mul(fill.Quantity))
+	if o.ExecutedQuantity.IsPositive() {
+		o.AvgPx = o.notional.Div(o.ExecutedQuantity)
+	}
+	o.LastExecutedPrice = fill.Price
+	o.LastExecutedQuantity = fill.Quantity
+	o.Fills = append(o.Fills, fill)
 }
 
 func (o *Order) Cancel() {
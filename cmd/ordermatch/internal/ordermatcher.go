@@ -17,19 +17,353 @@ package internal
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
 )
 
+// tradesKept is how many trades the matcher remembers for trade capture requests
+const tradesKept = 100000
+
+// OrderMatcher holds the book of every symbol. It is safe for concurrent use: every exported
+// method holds its lock, and orders are handed out as copies that later changes to the book
+// leave alone.
 type OrderMatcher struct {
+	mu sync.RWMutex
+
 	markets map[string]*Market
+
+	// shared by every market so execution IDs are unique across symbols
+	execIDs *sequence
+
+	orderIDs *sequence
+	tradeIDs *sequence
+
+	// the last tradesKept trades, oldest first
+	trades []Trade
+
+	// every ClOrdID each firm has used on tradeDate, whether or not its order reached the book,
+	// and those of orders still resting from earlier days, to refuse duplicates
+	clOrdIDs  map[orderKey]bool
+	tradeDate string
+
+	// nil unless the book is journaled
+	journal *Journal
+
+	// the first error writing the journal, after which the book is not changed again
+	journalErr error
+
+	// nil unless orders are checked against pre-trade limits
+	risk *RiskLimits
+
+	// what happens to crossing orders of the same firm
+	stp SelfTradePrevention
 }
 
 func NewOrderMatcher() *OrderMatcher {
-	return &OrderMatcher{markets: make(map[string]*Market)}
+	return &OrderMatcher{
+		markets:  make(map[string]*Market),
+		execIDs:  new(sequence),
+		orderIDs: new(sequence),
+		tradeIDs: new(sequence),
+		clOrdIDs: make(map[orderKey]bool),
+	}
+}
+
+// OpenJournal rebuilds the book from the journal at path, then records every later change
+// to the book there
+func (m *OrderMatcher) OpenJournal(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	journal, events, err := OpenJournal(path)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		m.replay(event)
+	}
+	m.journal = journal
+
+	// the ClOrdIDs used today are those of resting orders and those reserved today
+	m.tradeDate = ""
+	m.rollTradeDate(time.Now())
+	for _, event := range events {
+		if event.Type == EventClOrdID && event.TradeDate == m.tradeDate {
+			m.clOrdIDs[orderKey{senderCompID: event.SenderCompID, clOrdID: event.ClOrdID}] = true
+		}
+	}
+
+	return nil
+}
+
+// CloseJournal stops journaling the book
+func (m *OrderMatcher) CloseJournal() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.journal == nil {
+		return nil
+	}
+
+	journal := m.journal
+	m.journal = nil
+	return journal.Close()
+}
+
+// record journals event, returning a JournalError if it or an earlier event could not be
+// written
+func (m *OrderMatcher) record(event Event) error {
+	if m.journal == nil {
+		return nil
+	}
+
+	if m.journalErr == nil {
+		m.journalErr = m.journal.Record(event)
+	}
+	if m.journalErr != nil {
+		return JournalError{Type: event.Type, Err: m.journalErr}
+	}
+
+	return nil
+}
+
+func (m *OrderMatcher) replay(event Event) {
+	switch event.Type {
+	case EventInsert:
+		if event.Order != nil {
+			_ = m.insert(*event.Order)
+		}
+	case EventCancel:
+		_, _ = m.cancel(event.SenderCompID, event.ClOrdID, event.Symbol, event.Side)
+	case EventReplace:
+		if event.Price != nil && event.Quantity != nil {
+			_, _ = m.replace(event.SenderCompID, event.OrigClOrdID, event.Symbol, event.Side, event.ClOrdID, *event.Price, *event.Quantity)
+		}
+	case EventFill:
+		if market, ok := m.markets[event.Symbol]; ok && event.Fill != nil {
+			m.execIDs.observe(event.Fill.ExecID)
+			market.apply(orderKey{senderCompID: event.SenderCompID, clOrdID: event.ClOrdID}, event.Side, *event.Fill)
+		}
+	case EventRestate:
+		if market, ok := m.markets[event.Symbol]; ok && event.Quantity != nil {
+			if order := market.list(event.Side).Find(orderKey{senderCompID: event.SenderCompID, clOrdID: event.ClOrdID}); order != nil {
+				market.restate(order, *event.Quantity)
+			}
+		}
+	case EventExecID:
+		m.execIDs.observe(event.ExecID)
+	case EventTrade:
+		if event.Trade != nil {
+			m.tradeIDs.observe(event.Trade.TradeID)
+			m.keepTrade(*event.Trade)
+		}
+	case EventOrderID:
+		m.orderIDs.observe(event.OrderID)
+	}
+}
+
+// SetSelfTradePrevention sets what happens when two crossing orders come from the same firm
+func (m *OrderMatcher) SetSelfTradePrevention(stp SelfTradePrevention) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stp = stp
+}
+
+// SetRiskLimits checks every later order against limits, nil turns the checks off
+func (m *OrderMatcher) SetRiskLimits(limits *RiskLimits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.risk = limits
+}
+
+// CheckRisk returns a RiskError if order breaks the limits of its firm or its symbol, nil if it
+// passes or no limits are set. An order already resting in the book is checked as amended:
+// what it has open is taken out of the exposure of its firm first.
+func (m *OrderMatcher) CheckRisk(order Order) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.risk == nil {
+		return nil
+	}
+
+	var reference decimal.Decimal
+	var hasReference bool
+	var inSymbol exposure
+	openOrders := 0
+	for symbol, market := range m.markets {
+		e := market.exposure(order.SenderCompID)
+		if symbol == order.Symbol {
+			if resting := market.list(order.Side).Find(order.key()); resting != nil {
+				e = e.without(resting)
+			}
+			inSymbol = e
+			reference, hasReference = market.reference()
+		}
+		openOrders += e.openOrders
+	}
+
+	limits, ok := m.risk.Senders[order.SenderCompID]
+	if !ok {
+		limits = m.risk.Default
+	}
+	if err := limits.check(order, reference, hasReference, openOrders, inSymbol); err != nil {
+		return err
+	}
+
+	if limits, ok := m.risk.Symbols[order.Symbol]; ok {
+		return limits.check(order, reference, hasReference, inSymbol.openOrders, inSymbol)
+	}
+
+	return nil
+}
+
+// NextExecID returns a new execution ID for a report that is not a fill
+func (m *OrderMatcher) NextExecID() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	execID := m.execIDs.next()
+	if err := m.record(Event{Type: EventExecID, ExecID: execID}); err != nil {
+		return "", err
+	}
+
+	return execID, nil
+}
+
+// NextOrderID returns a new order ID for an incoming order
+func (m *OrderMatcher) NextOrderID() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orderID := m.orderIDs.next()
+	if err := m.record(Event{Type: EventOrderID, OrderID: orderID}); err != nil {
+		return "", err
+	}
+
+	return orderID, nil
+}
+
+// Duplicate returns true if senderCompID has already used clOrdID today, or for an order still
+// resting from an earlier day
+func (m *OrderMatcher) Duplicate(senderCompID, clOrdID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rollTradeDate(time.Now())
+	return m.clOrdIDs[orderKey{senderCompID: senderCompID, clOrdID: clOrdID}]
+}
+
+// UseClOrdID reserves clOrdID for senderCompID for the rest of the trade date, whatever becomes
+// of the order or request using it, returning false if it is a duplicate. A JournalError means
+// it was not reserved.
+func (m *OrderMatcher) UseClOrdID(senderCompID, clOrdID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := orderKey{senderCompID: senderCompID, clOrdID: clOrdID}
+	m.rollTradeDate(time.Now())
+	if m.clOrdIDs[key] {
+		return false, nil
+	}
+
+	if err := m.record(Event{Type: EventClOrdID, SenderCompID: senderCompID, ClOrdID: clOrdID, TradeDate: m.tradeDate}); err != nil {
+		return false, err
+	}
+	m.clOrdIDs[key] = true
+
+	return true, nil
+}
+
+// useClOrdID marks the ClOrdID of key used for the trade date
+func (m *OrderMatcher) useClOrdID(key orderKey) {
+	m.rollTradeDate(time.Now())
+	m.clOrdIDs[key] = true
+}
+
+// rollTradeDate forgets the ClOrdIDs used before the UTC date of now, other than those of
+// orders still resting, so they may be used again
+func (m *OrderMatcher) rollTradeDate(now time.Time) {
+	tradeDate := now.UTC().Format("20060102")
+	if tradeDate == m.tradeDate {
+		return
+	}
+	m.tradeDate = tradeDate
+
+	m.clOrdIDs = make(map[orderKey]bool)
+	for _, market := range m.markets {
+		bids, offers := market.Orders()
+		for _, order := range append(bids, offers...) {
+			m.clOrdIDs[order.key()] = true
+		}
+	}
+}
+
+// Trades returns the trades in symbol, or in every symbol if symbol is empty, that took place
+// between from and to. A zero from or to leaves that end open.
+func (m *OrderMatcher) Trades(symbol string, from, to time.Time) (trades []Trade) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, trade := range m.trades {
+		switch {
+		case symbol != "" && trade.Symbol != symbol:
+		case !from.IsZero() && trade.Time.Before(from):
+		case !to.IsZero() && trade.Time.After(to):
+		default:
+			trades = append(trades, trade)
+		}
+	}
+
+	return
+}
+
+// Fills returns the fill history of the order with orderID in symbol, false if there is no such order
+func (m *OrderMatcher) Fills(symbol, orderID string) ([]Fill, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return nil, false
+	}
+
+	order := market.Order(orderID)
+	if order == nil {
+		return nil, false
+	}
+
+	return append([]Fill(nil), order.Fills...), true
+}
+
+// DisplayFills prints the fill history of an order
+func (m *OrderMatcher) DisplayFills(symbol, orderID string) {
+	fills, ok := m.Fills(symbol, orderID)
+	if !ok {
+		fmt.Println("===============")
+		fmt.Println("ORDER NOT FOUND")
+		fmt.Println("===============")
+		return
+	}
+
+	fmt.Printf("FILLS %v %v:\n", symbol, orderID)
+	fmt.Println("-----")
+	for _, fill := range fills {
+		fmt.Printf("%+v\n", fill)
+	}
 }
 
-func (m OrderMatcher) DisplayMarket(symbol string) {
+func (m *OrderMatcher) DisplayMarket(symbol string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	if market, ok := m.markets[symbol]; ok {
 		market.Display()
 		return
@@ -39,7 +373,10 @@ func (m OrderMatcher) DisplayMarket(symbol string) {
 	fmt.Println("================")
 }
 
-func (m OrderMatcher) Display() {
+func (m *OrderMatcher) Display() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	hasMarkets := len(m.markets) > 0
 	if hasMarkets {
 		fmt.Println("===============")
@@ -55,30 +392,292 @@ func (m OrderMatcher) Display() {
 	fmt.Println("===========================")
 }
 
-func (m *OrderMatcher) Insert(order Order) {
+// Symbols returns the symbols with a book, in alphabetical order
+func (m *OrderMatcher) Symbols() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	symbols := make([]string, 0, len(m.markets))
+	for symbol := range m.markets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+// Depth returns the aggregated book for symbol, false if there is no market for the symbol
+func (m *OrderMatcher) Depth(symbol string, depth int) (bids, offers []PriceLevel, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return
+	}
+
+	bids, offers = market.Depth(depth)
+	return
+}
+
+// Orders returns copies of the orders resting in the book of symbol, in priority order, false
+// if there is no market for the symbol
+func (m *OrderMatcher) Orders(symbol string) (bids, offers []Order, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return
+	}
+
+	copyOut := func(orders []*Order) []Order {
+		copies := make([]Order, 0, len(orders))
+		for _, order := range orders {
+			copied, _ := clone(order, nil)
+			copies = append(copies, *copied)
+		}
+		return copies
+	}
+
+	restingBids, restingOffers := market.Orders()
+	return copyOut(restingBids), copyOut(restingOffers), true
+}
+
+// OrderByID returns a copy of the order with orderID in any symbol, resting or as it left the
+// book, nil if there is no such order
+func (m *OrderMatcher) OrderByID(orderID string) *Order {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, market := range m.markets {
+		if order := market.Order(orderID); order != nil {
+			copied, _ := clone(order, nil)
+			return copied
+		}
+	}
+
+	return nil
+}
+
+// Insert rests order in the book of its symbol, unless it cannot be journaled
+func (m *OrderMatcher) Insert(order Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insert(order)
+}
+
+func (m *OrderMatcher) insert(order Order) error {
+	if err := m.record(Event{Type: EventInsert, Order: &order}); err != nil {
+		return err
+	}
+
 	market, ok := m.markets[order.Symbol]
 	if !ok {
 		market = NewMarket()
+		market.execIDs = m.execIDs
 		m.markets[order.Symbol] = market
 	}
+	m.useClOrdID(order.key())
 
 	market.Insert(order)
+	return nil
 }
 
-func (m *OrderMatcher) Cancel(clordID, symbol string, side enum.Side) *Order {
+// CanFill returns true if order could be filled entirely by the orders resting in its market,
+// leaving out those self-trade prevention keeps it from trading with
+func (m *OrderMatcher) CanFill(order Order) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	market, ok := m.markets[order.Symbol]
+	if !ok {
+		return false
+	}
+
+	return market.CanFill(order, m.stp)
+}
+
+// Cancel cancels the resting order clordID of senderCompID
+func (m *OrderMatcher) Cancel(senderCompID, clordID, symbol string, side enum.Side) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return clone(m.cancel(senderCompID, clordID, symbol, side))
+}
+
+func (m *OrderMatcher) cancel(senderCompID, clordID, symbol string, side enum.Side) (*Order, error) {
+	if err := m.record(Event{Type: EventCancel, Symbol: symbol, Side: side, SenderCompID: senderCompID, ClOrdID: clordID}); err != nil {
+		return nil, err
+	}
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return nil, ErrUnknownOrder
+	}
+
+	return market.Cancel(senderCompID, clordID, side)
+}
+
+// Find returns the resting order clordID of senderCompID, or the order as it left the book if
+// it has been filled or canceled, nil if there is no such order
+func (m *OrderMatcher) Find(senderCompID, clordID, symbol string, side enum.Side) *Order {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	market, ok := m.markets[symbol]
 	if !ok {
 		return nil
 	}
 
-	return market.Cancel(clordID, side)
+	order, _ := clone(market.Find(senderCompID, clordID, side), nil)
+	return order
 }
 
-func (m *OrderMatcher) Match(symbol string) []Order {
+// Replace amends the resting order origClOrdID of senderCompID, giving it clOrdID
+func (m *OrderMatcher) Replace(senderCompID, origClOrdID, symbol string, side enum.Side, clOrdID string, price, quantity decimal.Decimal) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return clone(m.replace(senderCompID, origClOrdID, symbol, side, clOrdID, price, quantity))
+}
+
+func (m *OrderMatcher) replace(senderCompID, origClOrdID, symbol string, side enum.Side, clOrdID string, price, quantity decimal.Decimal) (*Order, error) {
+	if err := m.record(Event{Type: EventReplace, Symbol: symbol, Side: side, SenderCompID: senderCompID, ClOrdID: clOrdID,
+		OrigClOrdID: origClOrdID, Price: &price, Quantity: &quantity}); err != nil {
+		return nil, err
+	}
+
 	market, ok := m.markets[symbol]
 	if !ok {
-		return []Order{}
+		return nil, ErrUnknownOrder
+	}
+
+	order, err := market.Replace(senderCompID, origClOrdID, side, clOrdID, price, quantity)
+	if err == nil {
+		m.useClOrdID(order.key())
+	}
+
+	return order, err
+}
+
+// Match trades the crossing orders in the book of symbol, returning the trades and the orders
+// canceled or reduced by self-trade prevention. A JournalError means the book may hold trades
+// the journal lacks, which must not be reported.
+func (m *OrderMatcher) Match(symbol string) ([]Trade, []Prevention, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.writable(EventTrade); err != nil {
+		return nil, nil, err
+	}
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return []Trade{}, nil, nil
+	}
+
+	trades, prevented := market.Match(m.stp)
+	return trades, prevented, m.recordMatch(symbol, trades, prevented)
+}
+
+// Uncross ends the call auction of symbol, trading every crossing order at a single clearing
+// price. It returns the trades and the orders canceled or reduced by self-trade prevention,
+// and like Match a JournalError if they could not all be journaled.
+func (m *OrderMatcher) Uncross(symbol string) ([]Trade, []Prevention, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.writable(EventTrade); err != nil {
+		return nil, nil, err
+	}
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return []Trade{}, nil, nil
+	}
+
+	trades, prevented := market.Uncross(m.stp)
+	return trades, prevented, m.recordMatch(symbol, trades, prevented)
+}
+
+// Indicate returns where the book of symbol would uncross if its call auction ended now,
+// false if there is no market for the symbol
+func (m *OrderMatcher) Indicate(symbol string) (Indication, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	market, ok := m.markets[symbol]
+	if !ok {
+		return Indication{}, false
+	}
+
+	return market.Indicate(), true
+}
+
+// recordMatch assigns trade IDs to trades and journals them, with the fills and the self-trade
+// preventions that came with them, returning the first JournalError
+func (m *OrderMatcher) recordMatch(symbol string, trades []Trade, prevented []Prevention) error {
+	var events []Event
+	for i := range trades {
+		trades[i].TradeID = m.tradeIDs.next()
+		m.keepTrade(trades[i])
+		events = append(events, Event{Type: EventTrade, Symbol: symbol, Trade: &trades[i]})
+	}
+
+	for _, trade := range trades {
+		for _, order := range []Order{trade.Aggressor, trade.Resting} {
+			fill, _ := order.LastFill()
+			events = append(events, Event{Type: EventFill, Symbol: symbol, Side: order.Side, SenderCompID: order.SenderCompID, ClOrdID: order.ClOrdID, Fill: &fill})
+		}
+	}
+
+	for _, p := range prevented {
+		if p.Canceled {
+			events = append(events, Event{Type: EventCancel, Symbol: symbol, Side: p.Order.Side, SenderCompID: p.Order.SenderCompID, ClOrdID: p.Order.ClOrdID})
+			continue
+		}
+
+		quantity := p.Order.Quantity
+		events = append(events, Event{Type: EventRestate, Symbol: symbol, Side: p.Order.Side, SenderCompID: p.Order.SenderCompID, ClOrdID: p.Order.ClOrdID,
+			Quantity: &quantity})
+	}
+
+	for _, event := range events {
+		if err := m.record(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writable returns a JournalError for an event of eventType if the journal has already failed
+func (m *OrderMatcher) writable(eventType EventType) error {
+	if m.journal != nil && m.journalErr != nil {
+		return JournalError{Type: eventType, Err: m.journalErr}
+	}
+
+	return nil
+}
+
+// keepTrade remembers trade, forgetting the oldest once tradesKept are remembered
+func (m *OrderMatcher) keepTrade(trade Trade) {
+	m.trades = append(m.trades, trade)
+	if len(m.trades) > tradesKept {
+		m.trades[0] = Trade{}
+		m.trades = m.trades[1:]
+	}
+}
+
+// clone copies order out of the book so the caller can read it without holding the lock
+func clone(order *Order, err error) (*Order, error) {
+	if order == nil {
+		return nil, err
 	}
 
-	return market.Match()
+	copied := *order
+	copied.Fills = append([]Fill(nil), order.Fills...)
+	return &copied, err
 }
@@ -0,0 +1,282 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// testOrder returns a day limit order of senderCompID
+func testOrder(senderCompID, clOrdID, symbol string, side enum.Side, price, quantity int64) Order {
+	return Order{
+		ClOrdID:      clOrdID,
+		Symbol:       symbol,
+		SenderCompID: senderCompID,
+		Side:         side,
+		OrdType:      enum.OrdType_LIMIT,
+		TimeInForce:  enum.TimeInForce_DAY,
+		Price:        decimal.NewFromInt(price),
+		Quantity:     decimal.NewFromInt(quantity),
+	}
+}
+
+// TestOrderMatcherConcurrentSessions drives one matcher from many goroutines, each trading as
+// a firm of its own the way sessions do, while others read the book. Run it with -race.
+func TestOrderMatcherConcurrentSessions(t *testing.T) {
+	// Display prints the book, which is of no interest here
+	stdout := os.Stdout
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+
+	const (
+		firms  = 8
+		orders = 200
+	)
+	symbols := []string{"ABC", "XYZ"}
+
+	m := NewOrderMatcher()
+
+	var wg sync.WaitGroup
+	for f := 0; f < firms; f++ {
+		wg.Add(1)
+		go func(firm string, f int) {
+			defer wg.Done()
+
+			for i := 0; i < orders; i++ {
+				symbol := symbols[i%len(symbols)]
+				side := enum.Side_BUY
+				if (i+f)%2 == 0 {
+					side = enum.Side_SELL
+				}
+
+				clOrdID := fmt.Sprintf("%v-%v", firm, i)
+				order := testOrder(firm, clOrdID, symbol, side, int64(95+i%10), 10)
+				orderID, err := m.NextOrderID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				order.OrderID = orderID
+				if err := m.Insert(order); err != nil {
+					t.Error(err)
+					return
+				}
+				m.Match(symbol)
+
+				switch i % 3 {
+				case 1:
+					_, _ = m.Cancel(firm, clOrdID, symbol, side)
+				case 2:
+					_, _ = m.Replace(firm, clOrdID, symbol, side, clOrdID+"r", decimal.NewFromInt(100), decimal.NewFromInt(20))
+					m.Match(symbol)
+				}
+			}
+		}(fmt.Sprintf("FIRM%v", f), f)
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				for _, symbol := range m.Symbols() {
+					m.Depth(symbol, 5)
+					m.DisplayMarket(symbol)
+				}
+				m.Display()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	for _, symbol := range symbols {
+		m.Match(symbol)
+
+		bids, offers, ok := m.Depth(symbol, 1)
+		if !ok {
+			t.Fatalf("no book for %v", symbol)
+		}
+		if len(bids) > 0 && len(offers) > 0 && bids[0].Price.GreaterThanOrEqual(offers[0].Price) {
+			t.Errorf("%v is left crossed: bid %v, offer %v", symbol, bids[0].Price, offers[0].Price)
+		}
+	}
+
+	// every trade takes the same quantity off both sides
+	bought, sold := decimal.Zero, decimal.Zero
+	for _, trade := range m.Trades("", time.Time{}, time.Time{}) {
+		for _, order := range []Order{trade.Aggressor, trade.Resting} {
+			fill, _ := order.LastFill()
+			if order.Side == enum.Side_BUY {
+				bought = bought.Add(fill.Quantity)
+			} else {
+				sold = sold.Add(fill.Quantity)
+			}
+		}
+	}
+	if !bought.Equal(sold) || !bought.IsPositive() {
+		t.Errorf("bought %v and sold %v", bought, sold)
+	}
+}
+
+// TestJournalFailureRefusesChanges checks that once the journal cannot be written the book is
+// left as the journal has it
+func TestJournalFailureRefusesChanges(t *testing.T) {
+	m := NewOrderMatcher()
+	if err := m.OpenJournal(filepath.Join(t.TempDir(), "ordermatch.journal")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Insert(testOrder("FIRM", "1", "ABC", enum.Side_SELL, 100, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// every later write fails
+	m.journal.file.Close()
+
+	var journalErr JournalError
+	if _, err := m.NextOrderID(); !errors.As(err, &journalErr) {
+		t.Errorf("NextOrderID: got %v, want a JournalError", err)
+	}
+	if _, err := m.NextExecID(); !errors.As(err, &journalErr) {
+		t.Errorf("NextExecID: got %v, want a JournalError", err)
+	}
+	if err := m.Insert(testOrder("FIRM", "2", "ABC", enum.Side_BUY, 100, 10)); !errors.As(err, &journalErr) {
+		t.Errorf("Insert: got %v, want a JournalError", err)
+	}
+	if _, err := m.Cancel("FIRM", "1", "ABC", enum.Side_SELL); !errors.As(err, &journalErr) {
+		t.Errorf("Cancel: got %v, want a JournalError", err)
+	}
+	if _, err := m.Replace("FIRM", "1", "ABC", enum.Side_SELL, "1r", decimal.NewFromInt(101), decimal.NewFromInt(10)); !errors.As(err, &journalErr) {
+		t.Errorf("Replace: got %v, want a JournalError", err)
+	}
+	if _, _, err := m.Match("ABC"); !errors.As(err, &journalErr) {
+		t.Errorf("Match: got %v, want a JournalError", err)
+	}
+
+	bids, offers, _ := m.Orders("ABC")
+	if len(bids) != 0 || len(offers) != 1 || offers[0].ClOrdID != "1" {
+		t.Errorf("book changed after the journal failed: bids %v, offers %v", bids, offers)
+	}
+}
+
+// TestDuplicateForgetsEarlierDays checks that a ClOrdID may be used again on a later trade
+// date, unless its order is still resting
+func TestDuplicateForgetsEarlierDays(t *testing.T) {
+	m := NewOrderMatcher()
+	if err := m.Insert(testOrder("FIRM", "resting", "ABC", enum.Side_BUY, 100, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Insert(testOrder("FIRM", "canceled", "ABC", enum.Side_BUY, 100, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Cancel("FIRM", "canceled", "ABC", enum.Side_BUY); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.Duplicate("FIRM", "resting") || !m.Duplicate("FIRM", "canceled") {
+		t.Fatal("ClOrdIDs used today are not duplicates")
+	}
+
+	m.rollTradeDate(time.Now().AddDate(0, 0, -1))
+	if !m.Duplicate("FIRM", "resting") {
+		t.Error("ClOrdID of a resting order is not a duplicate on a later day")
+	}
+	if m.Duplicate("FIRM", "canceled") {
+		t.Error("ClOrdID of a canceled order is still a duplicate on a later day")
+	}
+}
+
+// TestUseClOrdIDSurvivesRestart checks that a ClOrdID reserved for an order that never reached
+// the book stays used for the trade date, after a restart as well, and not on a later day
+func TestUseClOrdIDSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ordermatch.journal")
+
+	m := NewOrderMatcher()
+	if err := m.OpenJournal(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// yesterday is a reservation journaled on an earlier trade date
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("20060102")
+	if err := m.journal.Record(Event{Type: EventClOrdID, SenderCompID: "FIRM", ClOrdID: "yesterday", TradeDate: yesterday}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, clOrdID := range []string{"killed", "rejected"} {
+		if used, err := m.UseClOrdID("FIRM", clOrdID); err != nil || !used {
+			t.Fatalf("UseClOrdID %v: got %v, %v, want true", clOrdID, used, err)
+		}
+	}
+	if err := m.CloseJournal(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewOrderMatcher()
+	if err := restarted.OpenJournal(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		senderCompID, clOrdID string
+		used                  bool
+	}{
+		{"FIRM", "killed", false},
+		{"FIRM", "rejected", false},
+		{"FIRM", "yesterday", true},
+		{"OTHER", "killed", true},
+		{"FIRM", "new", true},
+		{"FIRM", "new", false},
+	}
+
+	for _, test := range tests {
+		used, err := restarted.UseClOrdID(test.senderCompID, test.clOrdID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != test.used {
+			t.Errorf("UseClOrdID %v %v: got %v, want %v", test.senderCompID, test.clOrdID, used, test.used)
+		}
+	}
+}
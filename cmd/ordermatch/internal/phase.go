@@ -0,0 +1,276 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Phase is the trading phase of the market or of a symbol
+type Phase string
+
+const (
+	PhaseClosed         Phase = "closed"
+	PhasePreOpen        Phase = "pre_open"
+	PhaseOpeningAuction Phase = "opening_auction"
+	PhaseContinuous     Phase = "continuous"
+	PhaseHalted         Phase = "halted"
+	PhaseClosingAuction Phase = "closing_auction"
+	PhasePostClose      Phase = "post_close"
+)
+
+// ParsePhase returns the phase named s
+func ParsePhase(s string) (Phase, error) {
+	switch phase := Phase(s); phase {
+	case PhaseClosed, PhasePreOpen, PhaseOpeningAuction, PhaseContinuous, PhaseHalted, PhaseClosingAuction, PhasePostClose:
+		return phase, nil
+	}
+
+	return "", fmt.Errorf("unknown trading phase %q, expected %v, %v, %v, %v, %v, %v or %v", s,
+		PhaseClosed, PhasePreOpen, PhaseOpeningAuction, PhaseContinuous, PhaseHalted, PhaseClosingAuction, PhasePostClose)
+}
+
+// AcceptsOrders returns true if new orders and replaces are accepted in the phase. Cancels
+// are accepted in every phase.
+func (p Phase) AcceptsOrders() bool {
+	switch p {
+	case PhasePreOpen, PhaseOpeningAuction, PhaseContinuous, PhaseClosingAuction:
+		return true
+	}
+
+	return false
+}
+
+// Matching returns true if crossing orders trade as they arrive in the phase, in any other
+// phase accepted orders rest in the book until continuous trading starts
+func (p Phase) Matching() bool {
+	return p == PhaseContinuous
+}
+
+// Auction returns true if orders accumulate in the phase for a call auction, which uncrosses
+// when the phase ends
+func (p Phase) Auction() bool {
+	return p == PhaseOpeningAuction || p == PhaseClosingAuction
+}
+
+// timeOfDay is a time since midnight, written 15:04 or 15:04:05
+type timeOfDay time.Duration
+
+func (t *timeOfDay) UnmarshalYAML(value *yaml.Node) error {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if parsed, err := time.Parse(layout, value.Value); err == nil {
+			*t = clock(parsed)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("line %v: time of day %q is not HH:MM or HH:MM:SS", value.Line, value.Value)
+}
+
+func (p *Phase) UnmarshalYAML(value *yaml.Node) error {
+	phase, err := ParsePhase(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %v: %s", value.Line, err)
+	}
+
+	*p = phase
+	return nil
+}
+
+// clock returns the wall clock time of day of t
+func clock(t time.Time) timeOfDay {
+	return timeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+}
+
+// Transition starts a phase at a time of day
+type Transition struct {
+	At    timeOfDay `yaml:"at"`
+	Phase Phase     `yaml:"phase"`
+}
+
+// transitions is a daily timetable of phases, earliest first
+type transitions []Transition
+
+// at returns the phase in force at time of day t, the last phase of the day before the first
+// transition
+func (ts transitions) at(t timeOfDay) Phase {
+	i := sort.Search(len(ts), func(i int) bool { return ts[i].At > t })
+	if i == 0 {
+		i = len(ts)
+	}
+
+	return ts[i-1].Phase
+}
+
+// Schedule is the daily timetable of the trading phases of the market, and of the symbols
+// that keep hours of their own
+type Schedule struct {
+	// Location is the time zone of the timetable, local time when it is empty
+	Location string `yaml:"location"`
+
+	Market  transitions            `yaml:"market"`
+	Symbols map[string]transitions `yaml:"symbols"`
+
+	location *time.Location
+}
+
+// LoadSchedule reads the trading schedule at path
+func LoadSchedule(path string) (*Schedule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s := new(Schedule)
+	if err := yaml.NewDecoder(file).Decode(s); err != nil {
+		return nil, err
+	}
+
+	s.location = time.Local
+	if s.Location != "" {
+		if s.location, err = time.LoadLocation(s.Location); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Market) == 0 {
+		return nil, fmt.Errorf("schedule has no market phases")
+	}
+	sort.SliceStable(s.Market, func(i, j int) bool { return s.Market[i].At < s.Market[j].At })
+	for symbol, ts := range s.Symbols {
+		if len(ts) == 0 {
+			return nil, fmt.Errorf("schedule of %v has no phases", symbol)
+		}
+		sort.SliceStable(ts, func(i, j int) bool { return ts[i].At < ts[j].At })
+	}
+
+	return s, nil
+}
+
+// Phases tracks the trading phase of the market and of every symbol. Without a schedule
+// trading is continuous; halts override the schedule until they are lifted.
+type Phases struct {
+	schedule *Schedule
+
+	// market and symbols are the scheduled phases as of the last update, symbols holding
+	// the symbols with a schedule of their own
+	market  Phase
+	symbols map[string]Phase
+
+	marketHalted bool
+	halted       map[string]bool
+}
+
+func NewPhases() *Phases {
+	return &Phases{
+		market:  PhaseContinuous,
+		symbols: make(map[string]Phase),
+		halted:  make(map[string]bool),
+	}
+}
+
+// SetSchedule has the phases follow schedule from now on
+func (p *Phases) SetSchedule(schedule *Schedule, now time.Time) {
+	p.schedule = schedule
+	p.Update(now)
+}
+
+// Update moves the scheduled phases to those in force at now
+func (p *Phases) Update(now time.Time) {
+	if p.schedule == nil {
+		return
+	}
+
+	t := clock(now.In(p.schedule.location))
+
+	p.market = p.schedule.Market.at(t)
+	for symbol, ts := range p.schedule.Symbols {
+		p.symbols[symbol] = ts.at(t)
+	}
+}
+
+// Market returns the phase of the market, that of every symbol without hours of its own
+func (p *Phases) Market() Phase {
+	if p.marketHalted {
+		return PhaseHalted
+	}
+
+	return p.market
+}
+
+// Of returns the phase symbol trades in
+func (p *Phases) Of(symbol string) Phase {
+	if p.marketHalted || p.halted[symbol] {
+		return PhaseHalted
+	}
+
+	if phase, ok := p.symbols[symbol]; ok {
+		return phase
+	}
+
+	return p.market
+}
+
+// Own returns the symbols whose phase may differ from that of the market, those with hours
+// of their own and those halted on their own
+func (p *Phases) Own() []string {
+	var symbols []string
+	for symbol := range p.symbols {
+		symbols = append(symbols, symbol)
+	}
+	for symbol := range p.halted {
+		if _, ok := p.symbols[symbol]; !ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+// Halt stops trading in symbol, or in the whole market when symbol is empty, until it is
+// resumed. It returns false if trading was halted already.
+func (p *Phases) Halt(symbol string) bool {
+	if symbol == "" {
+		halted := p.marketHalted
+		p.marketHalted = true
+		return !halted
+	}
+
+	halted := p.halted[symbol]
+	p.halted[symbol] = true
+	return !halted
+}
+
+// Resume lifts the halt of symbol, or of the whole market when symbol is empty, returning
+// it to its scheduled phase. It returns false if trading was not halted.
+func (p *Phases) Resume(symbol string) bool {
+	if symbol == "" {
+		halted := p.marketHalted
+		p.marketHalted = false
+		return halted
+	}
+
+	halted := p.halted[symbol]
+	delete(p.halted, symbol)
+	return halted
+}
@@ -0,0 +1,171 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits caps the orders of a firm, a zero value leaves the limit off
+type Limits struct {
+	// MaxOrderQty and MaxNotional cap the size of a single order. The notional of a market
+	// order is taken at the reference price.
+	MaxOrderQty decimal.Decimal `yaml:"maxOrderQty"`
+	MaxNotional decimal.Decimal `yaml:"maxNotional"`
+
+	// PriceBand is how far, as a fraction of the reference price, a limit price may be from
+	// the last trade, or the mid of the book before the first trade
+	PriceBand decimal.Decimal `yaml:"priceBand"`
+
+	// MaxOpenOrders caps the orders the firm has resting in the book
+	MaxOpenOrders int `yaml:"maxOpenOrders"`
+
+	// MaxPosition caps the net position the firm would hold in the symbol if all its open
+	// orders on the side of the order, and the order itself, were filled
+	MaxPosition decimal.Decimal `yaml:"maxPosition"`
+}
+
+// RiskLimits are the pre-trade limits orders are checked against before they reach the book
+type RiskLimits struct {
+	// Default applies to every firm not listed in Senders
+	Default Limits `yaml:"default"`
+
+	// Senders holds the limits of a firm by SenderCompID, counting its orders in every symbol
+	Senders map[string]Limits `yaml:"senders"`
+
+	// Symbols holds limits by symbol, counting the orders of the firm in that symbol only
+	Symbols map[string]Limits `yaml:"symbols"`
+}
+
+// LoadRiskLimits reads the risk limits file at path
+func LoadRiskLimits(path string) (*RiskLimits, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	limits := new(RiskLimits)
+	if err := yaml.NewDecoder(file).Decode(limits); err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// RiskError is the limit an order breaks
+type RiskError struct {
+	Reason enum.OrdRejReason
+	Text   string
+}
+
+func (e RiskError) Error() string {
+	return e.Text
+}
+
+func exceeds(format string, args ...interface{}) RiskError {
+	return RiskError{Reason: enum.OrdRejReason_ORDER_EXCEEDS_LIMIT, Text: fmt.Sprintf(format, args...)}
+}
+
+// exposure is what a firm has at stake in a market
+type exposure struct {
+	openOrders int
+	position   decimal.Decimal
+	openBuy    decimal.Decimal
+	openSell   decimal.Decimal
+}
+
+// add counts order in the exposure, sign -1 takes it out again
+func (e *exposure) add(order *Order, sign int64) {
+	executed, open := order.ExecutedQuantity.Mul(decimal.NewFromInt(sign)), order.OpenQuantity().Mul(decimal.NewFromInt(sign))
+
+	if order.Side == enum.Side_BUY {
+		e.position = e.position.Add(executed)
+		e.openBuy = e.openBuy.Add(open)
+	} else {
+		e.position = e.position.Sub(executed)
+		e.openSell = e.openSell.Add(open)
+	}
+
+	if !order.IsClosed() {
+		e.openOrders += int(sign)
+	}
+}
+
+// without returns the exposure less the open quantity of order, as if it were not resting
+func (e exposure) without(order *Order) exposure {
+	if order.IsClosed() {
+		return e
+	}
+
+	e.openOrders--
+	if order.Side == enum.Side_BUY {
+		e.openBuy = e.openBuy.Sub(order.OpenQuantity())
+	} else {
+		e.openSell = e.openSell.Sub(order.OpenQuantity())
+	}
+
+	return e
+}
+
+// check returns a RiskError if order breaks limits given the exposure of its firm, nil otherwise.
+// openOrders counts the resting orders of the firm the limits apply to.
+func (l Limits) check(order Order, reference decimal.Decimal, hasReference bool, openOrders int, e exposure) error {
+	if l.MaxOrderQty.IsPositive() && order.Quantity.GreaterThan(l.MaxOrderQty) {
+		return exceeds("order quantity %v exceeds limit %v", order.Quantity, l.MaxOrderQty)
+	}
+
+	price := order.Price
+	if order.IsMarket() {
+		price = reference
+	}
+	if l.MaxNotional.IsPositive() && (!order.IsMarket() || hasReference) {
+		if notional := price.Mul(order.Quantity); notional.GreaterThan(l.MaxNotional) {
+			return exceeds("order notional %v exceeds limit %v", notional, l.MaxNotional)
+		}
+	}
+
+	if l.PriceBand.IsPositive() && !order.IsMarket() && hasReference && reference.IsPositive() {
+		if band := order.Price.Sub(reference).Abs().Div(reference); band.GreaterThan(l.PriceBand) {
+			return RiskError{
+				Reason: enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND,
+				Text:   fmt.Sprintf("order price %v outside band of %v around %v", order.Price, l.PriceBand, reference),
+			}
+		}
+	}
+
+	if l.MaxOpenOrders > 0 && openOrders >= l.MaxOpenOrders {
+		return exceeds("open orders at limit %v", l.MaxOpenOrders)
+	}
+
+	if l.MaxPosition.IsPositive() {
+		position := e.position.Add(e.openBuy).Add(order.OpenQuantity())
+		if order.Side != enum.Side_BUY {
+			position = e.position.Sub(e.openSell).Sub(order.OpenQuantity())
+		}
+
+		if position.Abs().GreaterThan(l.MaxPosition) {
+			return exceeds("position %v would exceed limit %v", position, l.MaxPosition)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// recount sums up the orders of senderCompID in the market the slow way
+func recount(m *Market, senderCompID string) (e exposure) {
+	count := func(order *Order) {
+		if order.SenderCompID != senderCompID {
+			return
+		}
+
+		executed := order.ExecutedQuantity
+		if order.Side != enum.Side_BUY {
+			executed = executed.Neg()
+		}
+		e.position = e.position.Add(executed)
+
+		if order.IsClosed() {
+			return
+		}
+		e.openOrders++
+		if order.Side == enum.Side_BUY {
+			e.openBuy = e.openBuy.Add(order.OpenQuantity())
+		} else {
+			e.openSell = e.openSell.Add(order.OpenQuantity())
+		}
+	}
+
+	for _, list := range []orderList{m.Bids, m.Offers} {
+		list.Each(func(order *Order) bool {
+			count(order)
+			return true
+		})
+	}
+	for _, order := range m.closed {
+		count(order)
+	}
+
+	return
+}
+
+func TestExposureFollowsOrders(t *testing.T) {
+	for _, stp := range []SelfTradePrevention{STPOff, STPDecrement, STPCancelBoth} {
+		m := NewMarket()
+		firms := []string{"A", "B", "C"}
+
+		for i := 0; i < 300; i++ {
+			firm := firms[i%len(firms)]
+			side := enum.Side_BUY
+			if i%2 == 1 {
+				side = enum.Side_SELL
+			}
+
+			clOrdID := fmt.Sprint(i)
+			m.Insert(testOrder(firm, clOrdID, "ABC", side, int64(98+i%5), int64(10+i%7)))
+
+			switch i % 4 {
+			case 1:
+				_, _ = m.Cancel(firm, clOrdID, side)
+			case 2:
+				_, _ = m.Replace(firm, clOrdID, side, clOrdID+"r", decimal.NewFromInt(100), decimal.NewFromInt(5))
+			}
+			m.Match(stp)
+
+			for _, firm := range firms {
+				got, want := m.exposure(firm), recount(m, firm)
+				if got.openOrders != want.openOrders || !got.position.Equal(want.position) ||
+					!got.openBuy.Equal(want.openBuy) || !got.openSell.Equal(want.openSell) {
+					t.Fatalf("stp %v, order %v: exposure of %v is %+v, recounted %+v", stp, i, firm, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckRiskOfReplace(t *testing.T) {
+	m := NewOrderMatcher()
+	m.SetRiskLimits(&RiskLimits{Default: Limits{MaxOrderQty: decimal.NewFromInt(100), MaxPosition: decimal.NewFromInt(100), MaxOpenOrders: 1}})
+
+	resting := testOrder("A", "1", "ABC", enum.Side_BUY, 10, 80)
+	if err := m.CheckRisk(resting); err != nil {
+		t.Fatalf("new order: %v", err)
+	}
+	m.Insert(resting)
+
+	for _, tc := range []struct {
+		quantity int64
+		passes   bool
+	}{
+		{90, true},
+		{100, true},
+		{101, false},
+	} {
+		replaced := resting
+		replaced.Quantity = decimal.NewFromInt(tc.quantity)
+
+		if err := m.CheckRisk(replaced); (err == nil) != tc.passes {
+			t.Errorf("replace to %v: got %v", tc.quantity, err)
+		}
+	}
+
+	// a second order of the firm is still held to the open order limit
+	if err := m.CheckRisk(testOrder("A", "2", "ABC", enum.Side_BUY, 10, 10)); err == nil {
+		t.Error("second order passed the open order limit")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// SelfTradePrevention is what the matcher does when two crossing orders come from the same firm
+type SelfTradePrevention string
+
+const (
+	// STPOff lets orders of the same firm trade with each other
+	STPOff SelfTradePrevention = ""
+
+	// STPCancelResting cancels the order resting in the book
+	STPCancelResting SelfTradePrevention = "cancel_resting"
+
+	// STPCancelAggressor cancels the incoming order
+	STPCancelAggressor SelfTradePrevention = "cancel_aggressor"
+
+	// STPCancelBoth cancels both orders
+	STPCancelBoth SelfTradePrevention = "cancel_both"
+
+	// STPDecrement takes the smaller quantity off both orders, canceling the smaller order
+	// and leaving what is left of the larger one
+	STPDecrement SelfTradePrevention = "decrement"
+)
+
+// ParseSelfTradePrevention returns the mode named s
+func ParseSelfTradePrevention(s string) (SelfTradePrevention, error) {
+	switch mode := SelfTradePrevention(s); mode {
+	case STPOff, STPCancelResting, STPCancelAggressor, STPCancelBoth, STPDecrement:
+		return mode, nil
+	}
+
+	return STPOff, fmt.Errorf("unknown self-trade prevention %q, expected %v, %v, %v or %v",
+		s, STPCancelResting, STPCancelAggressor, STPCancelBoth, STPDecrement)
+}
+
+// Prevention is an order canceled or reduced to keep it from trading with an order of its own firm
+type Prevention struct {
+	// the order as it stood right after
+	Order Order
+
+	// Canceled is true if what was left of the order was canceled, false if Quantity was
+	// taken off it
+	Canceled bool
+	Quantity decimal.Decimal
+}
+
+// list returns the side of the book orders of side rest on
+func (m *Market) list(side enum.Side) *orderList {
+	if side == enum.Side_BUY {
+		return &m.Bids
+	}
+
+	return &m.Offers
+}
+
+// preventSelfTrade keeps aggressor and resting, both from the same firm, from trading
+func (m *Market) preventSelfTrade(mode SelfTradePrevention, aggressor, resting *Order) (prevented []Prevention) {
+	cancel := func(order *Order) {
+		m.list(order.Side).Remove(order.key())
+		m.change(order, order.Cancel)
+		m.close(order)
+		prevented = append(prevented, Prevention{Order: *order, Canceled: true, Quantity: decimal.Zero})
+	}
+
+	switch mode {
+	case STPCancelResting:
+		cancel(resting)
+
+	case STPCancelAggressor:
+		cancel(aggressor)
+
+	case STPCancelBoth:
+		cancel(aggressor)
+		cancel(resting)
+
+	case STPDecrement:
+		quantity := decimal.Min(aggressor.OpenQuantity(), resting.OpenQuantity())
+		for _, order := range []*Order{aggressor, resting} {
+			if order.OpenQuantity().Equal(quantity) {
+				cancel(order)
+				continue
+			}
+
+			m.restate(order, order.Quantity.Sub(quantity))
+			prevented = append(prevented, Prevention{Order: *order, Quantity: quantity})
+		}
+	}
+
+	return
+}
+
+// restate reduces the quantity of a resting order, which keeps its time priority
+func (m *Market) restate(order *Order, quantity decimal.Decimal) {
+	m.change(order, func() { order.Quantity = quantity })
+	if order.IsClosed() {
+		m.list(order.Side).Remove(order.key())
+		m.close(order)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import "strconv"
+
+// sequence hands out increasing identifiers
+type sequence struct {
+	last int
+}
+
+func (s *sequence) next() string {
+	s.last++
+	return strconv.Itoa(s.last)
+}
+
+// observe moves the sequence past id, if id is one of its identifiers
+func (s *sequence) observe(id string) {
+	if n, err := strconv.Atoi(id); err == nil && n > s.last {
+		s.last = n
+	}
+}
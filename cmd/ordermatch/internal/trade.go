@@ -0,0 +1,44 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/shopspring/decimal"
+)
+
+// Trade is a single match between an incoming order and an order resting in the book
+type Trade struct {
+	// TradeID is assigned by the matcher, unique for the life of the journal
+	TradeID string
+
+	Symbol        string
+	Price         decimal.Decimal
+	Quantity      decimal.Decimal
+	AggressorSide enum.Side
+
+	// the orders as they stood right after the trade. An auction trade has no aggressor,
+	// Aggressor holds the buy order and Resting the sell order.
+	Aggressor Order
+	Resting   Order
+
+	// Auction is true for a trade of the uncross of a call auction
+	Auction bool
+
+	Time time.Time
+}
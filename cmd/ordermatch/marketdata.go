@@ -0,0 +1,326 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"fmt"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/tag"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix42mdr "github.com/quickfixgo/fix42/marketdatarequest"
+	fix43mdr "github.com/quickfixgo/fix43/marketdatarequest"
+	fix44mdr "github.com/quickfixgo/fix44/marketdatarequest"
+	fix50mdr "github.com/quickfixgo/fix50/marketdatarequest"
+)
+
+// marketDataRequest is the part of MarketDataRequest common to FIX 4.2 and later
+type marketDataRequest interface {
+	GetMDReqID() (string, quickfix.MessageRejectError)
+	GetSubscriptionRequestType() (enum.SubscriptionRequestType, quickfix.MessageRejectError)
+	GetMarketDepth() (int, quickfix.MessageRejectError)
+}
+
+// The repeating groups of outgoing market data messages. Field order within
+// the groups is the same for every FIX version that has market data.
+var (
+	snapshotEntries = quickfix.GroupTemplate{
+		quickfix.GroupElement(tag.MDEntryType),
+		quickfix.GroupElement(tag.MDEntryPx),
+		quickfix.GroupElement(tag.MDEntrySize),
+		quickfix.GroupElement(tag.NumberOfOrders),
+	}
+
+	incrementalEntries = quickfix.GroupTemplate{
+		quickfix.GroupElement(tag.MDUpdateAction),
+		quickfix.GroupElement(tag.MDEntryType),
+		quickfix.GroupElement(tag.Symbol),
+		quickfix.GroupElement(tag.MDEntryPx),
+		quickfix.GroupElement(tag.MDEntrySize),
+		quickfix.GroupElement(tag.NumberOfOrders),
+	}
+)
+
+// mdSubscription is a SNAPSHOT_PLUS_UPDATES market data request for a single symbol
+type mdSubscription struct {
+	sessionID  quickfix.SessionID
+	mdReqID    string
+	symbol     string
+	depth      int
+	entryTypes map[enum.MDEntryType]bool
+
+	// the book as last published to the subscriber
+	bids, offers []internal.PriceLevel
+}
+
+func (s mdSubscription) wants(entryType enum.MDEntryType) bool {
+	return len(s.entryTypes) == 0 || s.entryTypes[entryType]
+}
+
+func (a *Application) unsubscribe(sessionID quickfix.SessionID, mdReqID string) {
+	a.dropSubscriptions(func(sub *mdSubscription) bool {
+		return sub.sessionID == sessionID && sub.mdReqID == mdReqID
+	})
+}
+
+func (a *Application) unsubscribeSession(sessionID quickfix.SessionID) {
+	a.dropSubscriptions(func(sub *mdSubscription) bool {
+		return sub.sessionID == sessionID
+	})
+}
+
+func (a *Application) dropSubscriptions(drop func(sub *mdSubscription) bool) {
+	for symbol, subs := range a.subscriptions {
+		kept := subs[:0]
+		for _, sub := range subs {
+			if !drop(sub) {
+				kept = append(kept, sub)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(a.subscriptions, symbol)
+			continue
+		}
+		a.subscriptions[symbol] = kept
+	}
+}
+
+func (a *Application) onFIX42MarketDataRequest(msg fix42mdr.MarketDataRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	entryTypes, err := msg.GetNoMDEntryTypes()
+	if err != nil {
+		return err
+	}
+
+	relatedSym, err := msg.GetNoRelatedSym()
+	if err != nil {
+		return err
+	}
+
+	return a.onMarketDataRequest(msg, entryTypes.RepeatingGroup, relatedSym.RepeatingGroup, sessionID)
+}
+
+func (a *Application) onFIX43MarketDataRequest(msg fix43mdr.MarketDataRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	entryTypes, err := msg.GetNoMDEntryTypes()
+	if err != nil {
+		return err
+	}
+
+	relatedSym, err := msg.GetNoRelatedSym()
+	if err != nil {
+		return err
+	}
+
+	return a.onMarketDataRequest(msg, entryTypes.RepeatingGroup, relatedSym.RepeatingGroup, sessionID)
+}
+
+func (a *Application) onFIX44MarketDataRequest(msg fix44mdr.MarketDataRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	entryTypes, err := msg.GetNoMDEntryTypes()
+	if err != nil {
+		return err
+	}
+
+	relatedSym, err := msg.GetNoRelatedSym()
+	if err != nil {
+		return err
+	}
+
+	return a.onMarketDataRequest(msg, entryTypes.RepeatingGroup, relatedSym.RepeatingGroup, sessionID)
+}
+
+func (a *Application) onFIX50MarketDataRequest(msg fix50mdr.MarketDataRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	entryTypes, err := msg.GetNoMDEntryTypes()
+	if err != nil {
+		return err
+	}
+
+	relatedSym, err := msg.GetNoRelatedSym()
+	if err != nil {
+		return err
+	}
+
+	return a.onMarketDataRequest(msg, entryTypes.RepeatingGroup, relatedSym.RepeatingGroup, sessionID)
+}
+
+func (a *Application) onMarketDataRequest(msg marketDataRequest, entryTypeGroup, relatedSymGroup *quickfix.RepeatingGroup, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	mdReqID, err := msg.GetMDReqID()
+	if err != nil {
+		return err
+	}
+
+	subscriptionType, err := msg.GetSubscriptionRequestType()
+	if err != nil {
+		return err
+	}
+
+	if subscriptionType == enum.SubscriptionRequestType_DISABLE_PREVIOUS_SNAPSHOT_PLUS_UPDATE_REQUEST {
+		a.unsubscribe(sessionID, mdReqID)
+		return nil
+	}
+
+	depth, err := msg.GetMarketDepth()
+	if err != nil {
+		return err
+	}
+
+	entryTypes := make(map[enum.MDEntryType]bool)
+	for i := 0; i < entryTypeGroup.Len(); i++ {
+		var entryType field.MDEntryTypeField
+		if err := entryTypeGroup.Get(i).Get(&entryType); err != nil {
+			return err
+		}
+		entryTypes[entryType.Value()] = true
+	}
+
+	var symbols []string
+	for i := 0; i < relatedSymGroup.Len(); i++ {
+		var symbol field.SymbolField
+		if err := relatedSymGroup.Get(i).Get(&symbol); err != nil {
+			return err
+		}
+
+		if !a.listed(symbol.Value()) {
+			a.rejectMarketDataRequest(mdReqID, enum.MDReqRejReason_UNKNOWN_SYMBOL, fmt.Sprintf("unknown symbol: %v", symbol.Value()), sessionID)
+			return nil
+		}
+		symbols = append(symbols, symbol.Value())
+	}
+
+	// a request reusing an MDReqID replaces the earlier subscription
+	a.unsubscribe(sessionID, mdReqID)
+
+	for _, symbol := range symbols {
+		sub := &mdSubscription{
+			sessionID:  sessionID,
+			mdReqID:    mdReqID,
+			symbol:     symbol,
+			depth:      depth,
+			entryTypes: entryTypes,
+		}
+		a.sendSnapshot(sub)
+
+		if subscriptionType == enum.SubscriptionRequestType_SNAPSHOT_PLUS_UPDATES {
+			a.subscriptions[symbol] = append(a.subscriptions[symbol], sub)
+		}
+	}
+
+	return nil
+}
+
+func newMarketDataMessage(msgType enum.MsgType, mdReqID string) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(msgType))
+	msg.Body.Set(field.NewMDReqID(mdReqID))
+	return msg
+}
+
+func (a *Application) sendSnapshot(sub *mdSubscription) {
+	bids, offers, _ := a.Depth(sub.symbol, sub.depth)
+
+	snapshot := newMarketDataMessage(enum.MsgType_MARKET_DATA_SNAPSHOT_FULL_REFRESH, sub.mdReqID)
+	snapshot.Body.Set(field.NewSymbol(sub.symbol))
+
+	entries := quickfix.NewRepeatingGroup(tag.NoMDEntries, snapshotEntries)
+	addEntries := func(entryType enum.MDEntryType, levels []internal.PriceLevel) {
+		if !sub.wants(entryType) {
+			return
+		}
+
+		for _, level := range levels {
+			entry := entries.Add()
+			entry.Set(field.NewMDEntryType(entryType))
+			entry.Set(field.NewMDEntryPx(level.Price, 2))
+			entry.Set(field.NewMDEntrySize(level.Quantity, 2))
+			entry.Set(field.NewNumberOfOrders(level.Orders))
+		}
+	}
+	addEntries(enum.MDEntryType_BID, bids)
+	addEntries(enum.MDEntryType_OFFER, offers)
+	snapshot.Body.SetGroup(entries)
+
+	sub.bids, sub.offers = bids, offers
+
+	if err := quickfix.SendToTarget(snapshot, sub.sessionID); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// publishMarketData sends an incremental refresh to every subscriber of symbol for the
+// book changes since their last update, and for any trades
+func (a *Application) publishMarketData(symbol string, trades []internal.Trade) {
+	for _, sub := range a.subscriptions[symbol] {
+		bids, offers, _ := a.Depth(symbol, sub.depth)
+
+		entries := quickfix.NewRepeatingGroup(tag.NoMDEntries, incrementalEntries)
+		addUpdates := func(entryType enum.MDEntryType, updates []internal.LevelUpdate) {
+			if !sub.wants(entryType) {
+				return
+			}
+
+			for _, update := range updates {
+				entry := entries.Add()
+				entry.Set(field.NewMDUpdateAction(update.Action))
+				entry.Set(field.NewMDEntryType(entryType))
+				entry.Set(field.NewSymbol(symbol))
+				entry.Set(field.NewMDEntryPx(update.Price, 2))
+				if update.Action != enum.MDUpdateAction_DELETE {
+					entry.Set(field.NewMDEntrySize(update.Quantity, 2))
+					entry.Set(field.NewNumberOfOrders(update.Orders))
+				}
+			}
+		}
+		addUpdates(enum.MDEntryType_BID, internal.DiffLevels(sub.bids, bids))
+		addUpdates(enum.MDEntryType_OFFER, internal.DiffLevels(sub.offers, offers))
+
+		if sub.wants(enum.MDEntryType_TRADE) {
+			for _, trade := range trades {
+				entry := entries.Add()
+				entry.Set(field.NewMDUpdateAction(enum.MDUpdateAction_NEW))
+				entry.Set(field.NewMDEntryType(enum.MDEntryType_TRADE))
+				entry.Set(field.NewSymbol(symbol))
+				entry.Set(field.NewMDEntryPx(trade.Price, 2))
+				entry.Set(field.NewMDEntrySize(trade.Quantity, 2))
+			}
+		}
+
+		sub.bids, sub.offers = bids, offers
+
+		if entries.Len() == 0 {
+			continue
+		}
+
+		refresh := newMarketDataMessage(enum.MsgType_MARKET_DATA_INCREMENTAL_REFRESH, sub.mdReqID)
+		refresh.Body.SetGroup(entries)
+
+		if err := quickfix.SendToTarget(refresh, sub.sessionID); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func (a *Application) rejectMarketDataRequest(mdReqID string, reason enum.MDReqRejReason, text string, sessionID quickfix.SessionID) {
+	reject := newMarketDataMessage(enum.MsgType_MARKET_DATA_REQUEST_REJECT, mdReqID)
+	reject.Body.Set(field.NewMDReqRejReason(reason))
+	reject.Body.Set(field.NewText(text))
+
+	if err := quickfix.SendToTarget(reject, sessionID); err != nil {
+		fmt.Println(err)
+	}
+}
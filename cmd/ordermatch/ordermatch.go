@@ -18,74 +18,232 @@ package ordermatch
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
-	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/instruments"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
 	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
 	"github.com/quickfixgo/examples/cmd/utils"
 	"github.com/quickfixgo/field"
-	"github.com/quickfixgo/fix42/executionreport"
-	"github.com/quickfixgo/fix42/marketdatarequest"
-	"github.com/quickfixgo/fix42/newordersingle"
-	"github.com/quickfixgo/fix42/ordercancelrequest"
+	"github.com/quickfixgo/tag"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 
 	"github.com/quickfixgo/quickfix"
+
+	fix40nos "github.com/quickfixgo/fix40/newordersingle"
+	fix41nos "github.com/quickfixgo/fix41/newordersingle"
+	fix42nos "github.com/quickfixgo/fix42/newordersingle"
+	fix43nos "github.com/quickfixgo/fix43/newordersingle"
+	fix44nos "github.com/quickfixgo/fix44/newordersingle"
+	fix50nos "github.com/quickfixgo/fix50/newordersingle"
+
+	fix40cxl "github.com/quickfixgo/fix40/ordercancelrequest"
+	fix41cxl "github.com/quickfixgo/fix41/ordercancelrequest"
+	fix42cxl "github.com/quickfixgo/fix42/ordercancelrequest"
+	fix43cxl "github.com/quickfixgo/fix43/ordercancelrequest"
+	fix44cxl "github.com/quickfixgo/fix44/ordercancelrequest"
+	fix50cxl "github.com/quickfixgo/fix50/ordercancelrequest"
+
+	fix40ocrr "github.com/quickfixgo/fix40/ordercancelreplacerequest"
+	fix41ocrr "github.com/quickfixgo/fix41/ordercancelreplacerequest"
+	fix42ocrr "github.com/quickfixgo/fix42/ordercancelreplacerequest"
+	fix43ocrr "github.com/quickfixgo/fix43/ordercancelreplacerequest"
+	fix44ocrr "github.com/quickfixgo/fix44/ordercancelreplacerequest"
+	fix50ocrr "github.com/quickfixgo/fix50/ordercancelreplacerequest"
+
+	fix42mdr "github.com/quickfixgo/fix42/marketdatarequest"
+	fix43mdr "github.com/quickfixgo/fix43/marketdatarequest"
+	fix44mdr "github.com/quickfixgo/fix44/marketdatarequest"
+	fix50mdr "github.com/quickfixgo/fix50/marketdatarequest"
+
+	fix44tcrr "github.com/quickfixgo/fix44/tradecapturereportrequest"
+	fix50tcrr "github.com/quickfixgo/fix50/tradecapturereportrequest"
 )
 
+// newOrderSingle is the part of NewOrderSingle common to every supported FIX version
+type newOrderSingle interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetSymbol() (string, quickfix.MessageRejectError)
+	GetSide() (enum.Side, quickfix.MessageRejectError)
+	GetOrdType() (enum.OrdType, quickfix.MessageRejectError)
+	GetPrice() (decimal.Decimal, quickfix.MessageRejectError)
+	GetOrderQty() (decimal.Decimal, quickfix.MessageRejectError)
+	HasTimeInForce() bool
+	GetTimeInForce() (enum.TimeInForce, quickfix.MessageRejectError)
+}
+
+// orderCancelRequest is the part of OrderCancelRequest common to every supported FIX version
+type orderCancelRequest interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetOrigClOrdID() (string, quickfix.MessageRejectError)
+	GetSymbol() (string, quickfix.MessageRejectError)
+	GetSide() (enum.Side, quickfix.MessageRejectError)
+}
+
+// orderCancelReplaceRequest is the part of OrderCancelReplaceRequest common to every supported FIX version
+type orderCancelReplaceRequest interface {
+	GetClOrdID() (string, quickfix.MessageRejectError)
+	GetOrigClOrdID() (string, quickfix.MessageRejectError)
+	GetSymbol() (string, quickfix.MessageRejectError)
+	GetSide() (enum.Side, quickfix.MessageRejectError)
+	HasPrice() bool
+	GetPrice() (decimal.Decimal, quickfix.MessageRejectError)
+	HasOrderQty() bool
+	GetOrderQty() (decimal.Decimal, quickfix.MessageRejectError)
+}
+
 // Application implements the quickfix.Application interface
 type Application struct {
 	*quickfix.MessageRouter
 	*internal.OrderMatcher
-	execID int
+	subscriptions map[string][]*mdSubscription
+
+	// sessions flagged in the config to receive a copy of every trade
+	dropCopies map[quickfix.SessionID]bool
+
+	// instruments lists the symbols that may be traded, any symbol may be when it is nil
+	instruments *instruments.Master
+
+	// phases holds the trading phase of the market and of each symbol
+	phases *internal.Phases
+
+	// sessions holds every session of the config, true while it is logged on
+	sessions map[quickfix.SessionID]bool
+
+	// applVerIDs holds the FIX version each FIXT.1.1 session logged on with
+	applVerIDs map[quickfix.SessionID]enum.ApplVerID
+
+	// indications holds the last indication published for each symbol in a call auction
+	indications map[string]internal.Indication
+
+	// stopped receives the error the journal failed with, on which the service shuts down
+	stopped chan error
+
+	// serializes the messages of every session, so each request sees and leaves the book and
+	// the subscriptions as a whole
+	mu sync.Mutex
 }
 
 func newApplication() *Application {
 	app := &Application{
 		MessageRouter: quickfix.NewMessageRouter(),
 		OrderMatcher:  internal.NewOrderMatcher(),
+		subscriptions: make(map[string][]*mdSubscription),
+		dropCopies:    make(map[quickfix.SessionID]bool),
+		phases:        internal.NewPhases(),
+		sessions:      make(map[quickfix.SessionID]bool),
+		applVerIDs:    make(map[quickfix.SessionID]enum.ApplVerID),
+		indications:   make(map[string]internal.Indication),
+		stopped:       make(chan error, 1),
 	}
-	app.AddRoute(newordersingle.Route(app.onNewOrderSingle))
-	app.AddRoute(ordercancelrequest.Route(app.onOrderCancelRequest))
-	app.AddRoute(marketdatarequest.Route(app.onMarketDataRequest))
+	app.AddRoute(fix40nos.Route(messages.Route[fix40nos.NewOrderSingle](app.onNewOrderSingle)))
+	app.AddRoute(fix41nos.Route(messages.Route[fix41nos.NewOrderSingle](app.onNewOrderSingle)))
+	app.AddRoute(fix42nos.Route(messages.Route[fix42nos.NewOrderSingle](app.onNewOrderSingle)))
+	app.AddRoute(fix43nos.Route(messages.Route[fix43nos.NewOrderSingle](app.onNewOrderSingle)))
+	app.AddRoute(fix44nos.Route(messages.Route[fix44nos.NewOrderSingle](app.onNewOrderSingle)))
+	app.AddRoute(fix50nos.Route(messages.Route[fix50nos.NewOrderSingle](app.onNewOrderSingle)))
+
+	app.AddRoute(fix40cxl.Route(messages.Route[fix40cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+	app.AddRoute(fix41cxl.Route(messages.Route[fix41cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+	app.AddRoute(fix42cxl.Route(messages.Route[fix42cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+	app.AddRoute(fix43cxl.Route(messages.Route[fix43cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+	app.AddRoute(fix44cxl.Route(messages.Route[fix44cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+	app.AddRoute(fix50cxl.Route(messages.Route[fix50cxl.OrderCancelRequest](app.onOrderCancelRequest)))
+
+	app.AddRoute(fix40ocrr.Route(messages.Route[fix40ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+	app.AddRoute(fix41ocrr.Route(messages.Route[fix41ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+	app.AddRoute(fix42ocrr.Route(messages.Route[fix42ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+	app.AddRoute(fix43ocrr.Route(messages.Route[fix43ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+	app.AddRoute(fix44ocrr.Route(messages.Route[fix44ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+	app.AddRoute(fix50ocrr.Route(messages.Route[fix50ocrr.OrderCancelReplaceRequest](app.onOrderCancelReplaceRequest)))
+
+	app.AddRoute(fix42mdr.Route(app.onFIX42MarketDataRequest))
+	app.AddRoute(fix43mdr.Route(app.onFIX43MarketDataRequest))
+	app.AddRoute(fix44mdr.Route(app.onFIX44MarketDataRequest))
+	app.AddRoute(fix50mdr.Route(app.onFIX50MarketDataRequest))
+
+	app.AddRoute(fix44tcrr.Route(app.onFIX44TradeCaptureReportRequest))
+	app.AddRoute(fix50tcrr.Route(app.onFIX50TradeCaptureReportRequest))
+
+	app.addTradingSessionRoutes()
 
 	return app
 }
 
 // OnCreate implemented as part of Application interface
-func (a Application) OnCreate(_ quickfix.SessionID) {}
+func (a *Application) OnCreate(sessionID quickfix.SessionID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sessions[sessionID] = false
+}
 
 // OnLogon implemented as part of Application interface
-func (a Application) OnLogon(_ quickfix.SessionID) {}
+func (a *Application) OnLogon(sessionID quickfix.SessionID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sessions[sessionID] = true
+}
 
-// OnLogout implemented as part of Application interface
-func (a Application) OnLogout(_ quickfix.SessionID) {}
+// OnLogout implemented as part of Application interface, drops any market data subscriptions of the session
+func (a *Application) OnLogout(sessionID quickfix.SessionID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sessions[sessionID] = false
+	a.unsubscribeSession(sessionID)
+}
 
 // ToAdmin implemented as part of Application interface
-func (a Application) ToAdmin(_ *quickfix.Message, _ quickfix.SessionID) {}
+func (a *Application) ToAdmin(_ *quickfix.Message, _ quickfix.SessionID) {}
 
 // ToApp implemented as part of Application interface
-func (a Application) ToApp(_ *quickfix.Message, _ quickfix.SessionID) error {
+func (a *Application) ToApp(_ *quickfix.Message, _ quickfix.SessionID) error {
 	return nil
 }
 
-// FromAdmin implemented as part of Application interface
-func (a Application) FromAdmin(_ *quickfix.Message, _ quickfix.SessionID) quickfix.MessageRejectError {
+// FromAdmin implemented as part of Application interface, notes the FIX version a FIXT.1.1
+// session logs on with
+func (a *Application) FromAdmin(msg *quickfix.Message, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	if !sessionID.IsFIXT() || !msg.IsMsgTypeOf(string(enum.MsgType_LOGON)) {
+		return nil
+	}
+
+	var applVerID field.DefaultApplVerIDField
+	if err := msg.Body.Get(&applVerID); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.applVerIDs[sessionID] = enum.ApplVerID(applVerID.Value())
 	return nil
 }
 
 // FromApp implemented as part of Application interface, uses Router on incoming application messages
 func (a *Application) FromApp(msg *quickfix.Message, sessionID quickfix.SessionID) (reject quickfix.MessageRejectError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	return a.Route(msg, sessionID)
 }
 
-func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, _ quickfix.SessionID) quickfix.MessageRejectError {
+func (a *Application) onNewOrderSingle(msg newOrderSingle, sessionID quickfix.SessionID) quickfix.MessageRejectError {
 	clOrdID, err := msg.GetClOrdID()
 	if err != nil {
 		return err
@@ -96,16 +254,6 @@ func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, _ quic
 		return err
 	}
 
-	senderCompID, err := msg.Header.GetSenderCompID()
-	if err != nil {
-		return err
-	}
-
-	targetCompID, err := msg.Header.GetTargetCompID()
-	if err != nil {
-		return err
-	}
-
 	side, err := msg.GetSide()
 	if err != nil {
 		return err
@@ -116,9 +264,16 @@ func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, _ quic
 		return err
 	}
 
-	price, err := msg.GetPrice()
-	if err != nil {
-		return err
+	var price decimal.Decimal
+	switch ordType {
+	case enum.OrdType_LIMIT:
+		if price, err = msg.GetPrice(); err != nil {
+			return err
+		}
+	case enum.OrdType_MARKET:
+	default:
+		utils.PrintBad("incoming order was not a limit or market order and was rejected")
+		return quickfix.ValueIsIncorrect(tag.OrdType)
 	}
 
 	orderQty, err := msg.GetOrderQty()
@@ -126,31 +281,168 @@ func (a *Application) onNewOrderSingle(msg newordersingle.NewOrderSingle, _ quic
 		return err
 	}
 
+	timeInForce := enum.TimeInForce_DAY
+	if msg.HasTimeInForce() {
+		if timeInForce, err = msg.GetTimeInForce(); err != nil {
+			return err
+		}
+	}
+
 	order := internal.Order{
 		ClOrdID:      clOrdID,
 		Symbol:       symbol,
-		SenderCompID: senderCompID,
-		TargetCompID: targetCompID,
+		BeginString:  sessionID.BeginString,
+		SenderCompID: sessionID.TargetCompID,
+		TargetCompID: sessionID.SenderCompID,
 		Side:         side,
 		OrdType:      ordType,
+		TimeInForce:  timeInForce,
 		Price:        price,
 		Quantity:     orderQty,
 	}
 
-	a.Insert(order)
+	// the ClOrdID is used up from here on, whether or not the order reaches the book
+	used, useErr := a.UseClOrdID(order.SenderCompID, order.ClOrdID)
+	if useErr != nil {
+		a.stop(useErr)
+		return nil
+	}
+	if !used {
+		a.rejectOrder(order, enum.OrdRejReason_DUPLICATE_ORDER, internal.ErrDuplicateClOrdID.Error())
+		return nil
+	}
+
+	if !order.Quantity.IsPositive() {
+		a.rejectOrder(order, enum.OrdRejReason_INCORRECT_QUANTITY, internal.ErrInvalidQuantity.Error())
+		return nil
+	}
+
+	phase := a.phases.Of(order.Symbol)
+	if !phase.AcceptsOrders() {
+		a.rejectOrder(order, enum.OrdRejReason_EXCHANGE_CLOSED, fmt.Sprintf("%v is %v", order.Symbol, phase))
+		return nil
+	}
+
+	// outside continuous trading orders rest until trading starts, orders that must trade at
+	// once cannot
+	if !phase.Matching() && (order.IsMarket() || order.IsImmediate()) {
+		a.rejectOrder(order, enum.OrdRejReason_EXCHANGE_CLOSED, fmt.Sprintf("only limit day orders are accepted while %v is %v", order.Symbol, phase))
+		return nil
+	}
+
+	if err := a.instruments.CheckOrder(order.Symbol, order.Quantity, orderPrices(order)...); err != nil {
+		var instrumentErr instruments.Error
+		errors.As(err, &instrumentErr)
+		a.rejectOrder(order, instrumentErr.ReasonFor(order.BeginString), err.Error())
+		return nil
+	}
+
+	if err := a.CheckRisk(order); err != nil {
+		reason := enum.OrdRejReason_ORDER_EXCEEDS_LIMIT
+		var riskErr internal.RiskError
+		if errors.As(err, &riskErr) {
+			reason = riskErr.Reason
+		}
+		a.rejectOrder(order, reason, err.Error())
+		return nil
+	}
+
+	// only an order passing every check uses up an order ID
+	orderID, idErr := a.NextOrderID()
+	if idErr != nil {
+		a.stop(idErr)
+		return nil
+	}
+	order.OrderID = orderID
+
+	// a fill or kill order that cannot be filled entirely never reaches the book
+	if order.TimeInForce == enum.TimeInForce_FILL_OR_KILL && !a.CanFill(order) {
+		a.acceptOrder(order)
+		order.Cancel()
+		a.expireOrder(order, "fill or kill order could not be filled")
+		return nil
+	}
+
+	if err := a.Insert(order); err != nil {
+		a.stop(err)
+		return nil
+	}
 	a.acceptOrder(order)
 
-	matches := a.Match(order.Symbol)
+	if !phase.Matching() {
+		a.publishMarketData(order.Symbol, nil)
+		a.publishIndication(order.Symbol)
+		return nil
+	}
+
+	trades := a.match(order.Symbol)
+
+	if order.IsImmediate() {
+		expired, err := a.Cancel(order.SenderCompID, order.ClOrdID, order.Symbol, order.Side)
+		switch {
+		case err == nil:
+			a.expireOrder(*expired, "unfilled quantity canceled")
+		case errors.As(err, new(internal.JournalError)):
+			a.stop(err)
+		}
+	}
+
+	a.publishMarketData(order.Symbol, trades)
+
+	return nil
+}
+
+func (a *Application) onOrderCancelRequest(msg orderCancelRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	clOrdID, err := msg.GetClOrdID()
+	if err != nil {
+		return err
+	}
+
+	origClOrdID, err := msg.GetOrigClOrdID()
+	if err != nil {
+		return err
+	}
+
+	symbol, err := msg.GetSymbol()
+	if err != nil {
+		return err
+	}
+
+	side, err := msg.GetSide()
+	if err != nil {
+		return err
+	}
+
+	req := messages.CancelRequest{
+		ClOrdID:     clOrdID,
+		OrigClOrdID: origClOrdID,
+		ResponseTo:  enum.CxlRejResponseTo_ORDER_CANCEL_REQUEST,
+	}
 
-	for len(matches) > 0 {
-		a.fillOrder(matches[0])
-		matches = matches[1:]
+	order, cancelErr := a.Cancel(sessionID.TargetCompID, origClOrdID, symbol, side)
+	if cancelErr != nil {
+		a.rejectCancelRequest(req, a.Find(sessionID.TargetCompID, origClOrdID, symbol, side), cancelErr, sessionID)
+		if errors.As(cancelErr, new(internal.JournalError)) {
+			a.stop(cancelErr)
+		}
+		return nil
 	}
 
+	canceled := *order
+	canceled.ClOrdID, canceled.OrigClOrdID = clOrdID, order.ClOrdID
+	a.cancelOrder(canceled)
+	a.publishMarketData(symbol, nil)
+	a.publishIndication(symbol)
+
 	return nil
 }
 
-func (a *Application) onOrderCancelRequest(msg ordercancelrequest.OrderCancelRequest, _ quickfix.SessionID) quickfix.MessageRejectError {
+func (a *Application) onOrderCancelReplaceRequest(msg orderCancelReplaceRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	clOrdID, err := msg.GetClOrdID()
+	if err != nil {
+		return err
+	}
+
 	origClOrdID, err := msg.GetOrigClOrdID()
 	if err != nil {
 		return err
@@ -166,17 +458,156 @@ func (a *Application) onOrderCancelRequest(msg ordercancelrequest.OrderCancelReq
 		return err
 	}
 
-	order := a.Cancel(origClOrdID, symbol, side)
-	if order != nil {
-		a.cancelOrder(*order)
+	req := messages.CancelRequest{
+		ClOrdID:     clOrdID,
+		OrigClOrdID: origClOrdID,
+		ResponseTo:  enum.CxlRejResponseTo_ORDER_CANCEL_REPLACE_REQUEST,
+	}
+
+	resting := a.Find(sessionID.TargetCompID, origClOrdID, symbol, side)
+	if resting == nil {
+		a.rejectCancelRequest(req, nil, internal.ErrUnknownOrder, sessionID)
+		return nil
+	}
+
+	used, useErr := a.UseClOrdID(sessionID.TargetCompID, clOrdID)
+	if useErr != nil {
+		a.rejectCancelRequest(req, resting, useErr, sessionID)
+		a.stop(useErr)
+		return nil
+	}
+	if !used {
+		a.rejectCancelRequest(req, resting, internal.ErrDuplicateClOrdID, sessionID)
+		return nil
+	}
+
+	phase := a.phases.Of(symbol)
+	if !phase.AcceptsOrders() {
+		a.rejectCancelRequest(req, resting, fmt.Errorf("%v is %v", symbol, phase), sessionID)
+		return nil
+	}
+
+	price := resting.Price
+	if msg.HasPrice() {
+		if price, err = msg.GetPrice(); err != nil {
+			return err
+		}
+	}
+
+	orderQty := resting.Quantity
+	if msg.HasOrderQty() {
+		if orderQty, err = msg.GetOrderQty(); err != nil {
+			return err
+		}
+	}
+
+	if !orderQty.IsPositive() {
+		a.rejectCancelRequest(req, resting, internal.ErrInvalidQuantity, sessionID)
+		return nil
+	}
+
+	replaced := *resting
+	replaced.Price, replaced.Quantity = price, orderQty
+	if err := a.instruments.CheckOrder(symbol, orderQty, orderPrices(replaced)...); err != nil {
+		a.rejectCancelRequest(req, resting, err, sessionID)
+		return nil
+	}
+
+	if err := a.CheckRisk(replaced); err != nil {
+		a.rejectCancelRequest(req, resting, err, sessionID)
+		return nil
+	}
+
+	order, replaceErr := a.Replace(sessionID.TargetCompID, origClOrdID, symbol, side, clOrdID, price, orderQty)
+	if replaceErr != nil {
+		a.rejectCancelRequest(req, resting, replaceErr, sessionID)
+		if errors.As(replaceErr, new(internal.JournalError)) {
+			a.stop(replaceErr)
+		}
+		return nil
+	}
+	a.replaceOrder(*order)
+
+	var trades []internal.Trade
+	if phase.Matching() {
+		trades = a.match(symbol)
 	}
 
+	a.publishMarketData(symbol, trades)
+	a.publishIndication(symbol)
+
 	return nil
 }
 
-func (a *Application) onMarketDataRequest(msg marketdatarequest.MarketDataRequest, _ quickfix.SessionID) (err quickfix.MessageRejectError) {
-	fmt.Printf("%+v\n", msg)
-	return
+// match trades the crossing orders in the book of symbol and reports the fills, and the
+// orders self-trade prevention canceled or reduced. Nothing is reported if the trades could
+// not be journaled.
+func (a *Application) match(symbol string) []internal.Trade {
+	trades, prevented, err := a.Match(symbol)
+	if err != nil {
+		a.stop(err)
+		return nil
+	}
+	a.reportMatch(trades, prevented)
+
+	return trades
+}
+
+// reportMatch reports the fills of trades and the orders self-trade prevention canceled or
+// reduced, and sends the trades to the drop copy sessions
+func (a *Application) reportMatch(trades []internal.Trade, prevented []internal.Prevention) {
+	for _, trade := range trades {
+		a.fillOrder(trade.Aggressor)
+		a.fillOrder(trade.Resting)
+	}
+
+	for _, p := range prevented {
+		if p.Canceled {
+			a.expireOrder(p.Order, "canceled by self-trade prevention")
+			continue
+		}
+		a.restateOrder(p.Order, fmt.Sprintf("quantity reduced by %v by self-trade prevention", p.Quantity))
+	}
+
+	a.publishTrades(trades)
+}
+
+// listed returns true if symbol has a book or is in the instrument master, so a symbol
+// nobody has traded yet is known as soon as it is listed
+func (a *Application) listed(symbol string) bool {
+	if _, _, ok := a.Depth(symbol, 1); ok {
+		return true
+	}
+
+	if a.instruments == nil {
+		return false
+	}
+
+	_, ok := a.instruments.Lookup(symbol)
+	return ok
+}
+
+// orderPrices returns the prices of order the instrument master checks, none for a market order
+func orderPrices(order internal.Order) []decimal.Decimal {
+	if order.IsMarket() {
+		return nil
+	}
+
+	return []decimal.Decimal{order.Price}
+}
+
+// statusOf returns the current OrdStatus of order
+func statusOf(order internal.Order) enum.OrdStatus {
+	switch {
+	case order.IsCanceled():
+		return enum.OrdStatus_CANCELED
+	case order.IsClosed():
+		return enum.OrdStatus_FILLED
+	case order.ExecutedQuantity.IsPositive():
+		return enum.OrdStatus_PARTIALLY_FILLED
+	}
+
+	return enum.OrdStatus_NEW
 }
 
 func (a *Application) acceptOrder(order internal.Order) {
@@ -195,47 +626,147 @@ func (a *Application) cancelOrder(order internal.Order) {
 	a.updateOrder(order, enum.OrdStatus_CANCELED)
 }
 
-func (a *Application) genExecID() string {
-	a.execID++
-	return strconv.Itoa(a.execID)
+// restateOrder reports a change to an order the firm did not request, FIX.4.2 and later as
+// RESTATED, earlier versions as REPLACED
+func (a *Application) restateOrder(order internal.Order, text string) {
+	execType, status := enum.ExecType_RESTATED, statusOf(order)
+	switch order.BeginString {
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41:
+		execType, status = enum.ExecType_REPLACED, enum.OrdStatus_REPLACED
+	}
+
+	execReport, err := a.newExecutionReport(order, execType, status)
+	if err != nil {
+		a.stop(err)
+		return
+	}
+	execReport.Body.Set(field.NewText(text))
+	a.sendExecutionReport(order, execReport)
+}
+
+// rejectOrder reports an order refused before it reached the book, which has no order ID
+func (a *Application) rejectOrder(order internal.Order, reason enum.OrdRejReason, text string) {
+	order.OrderID = "NONE"
+	reason = ordRejReason(reason, order.BeginString, a.applVerIDs[sessionOf(order)])
+
+	order.Cancel()
+	execReport, err := a.newExecutionReport(order, enum.ExecType_REJECTED, enum.OrdStatus_REJECTED)
+	if err != nil {
+		a.stop(err)
+		return
+	}
+	execReport.Body.Set(field.NewOrdRejReason(reason))
+	execReport.Body.Set(field.NewText(text))
+	a.sendExecutionReport(order, execReport)
+}
+
+// ordRejReason returns reason as the FIX version of a session defines it. A price band is only
+// defined from FIX 5.0 SP1 on, an incorrect quantity from FIX 4.4 on and a duplicate order from
+// FIX 4.1 on. applVerID is the version a FIXT.1.1 session logged on with.
+func ordRejReason(reason enum.OrdRejReason, beginString string, applVerID enum.ApplVerID) enum.OrdRejReason {
+	switch reason {
+	case enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND:
+		if beginString == quickfix.BeginStringFIXT11 &&
+			(applVerID == enum.ApplVerID_FIX50SP1 || applVerID == enum.ApplVerID_FIX50SP2) {
+			return reason
+		}
+		return enum.OrdRejReason_ORDER_EXCEEDS_LIMIT
+
+	case enum.OrdRejReason_INCORRECT_QUANTITY:
+		if beginString == quickfix.BeginStringFIX44 || beginString == quickfix.BeginStringFIXT11 {
+			return reason
+		}
+		return enum.OrdRejReason_BROKER
+
+	case enum.OrdRejReason_DUPLICATE_ORDER:
+		if beginString == quickfix.BeginStringFIX40 {
+			return enum.OrdRejReason_BROKER
+		}
+	}
+
+	return reason
+}
+
+// expireOrder reports a cancel the firm did not request, such as what was left of an
+// immediate order after matching
+func (a *Application) expireOrder(order internal.Order, text string) {
+	execReport, err := a.newExecutionReport(order, enum.ExecType_CANCELED, enum.OrdStatus_CANCELED)
+	if err != nil {
+		a.stop(err)
+		return
+	}
+	execReport.Body.Set(field.NewText(text))
+	a.sendExecutionReport(order, execReport)
+}
+
+// replaceOrder reports a replace, FIX 4.3 and later report the status of the order after the
+// replace where earlier versions report REPLACED
+func (a *Application) replaceOrder(order internal.Order) {
+	status := statusOf(order)
+	switch order.BeginString {
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41, quickfix.BeginStringFIX42:
+		status = enum.OrdStatus_REPLACED
+	}
+
+	a.report(order, enum.ExecType_REPLACED, status)
 }
 
 func (a *Application) updateOrder(order internal.Order, status enum.OrdStatus) {
-	execReport := executionreport.New(
-		field.NewOrderID(order.ClOrdID),
-		field.NewExecID(a.genExecID()),
-		field.NewExecTransType(enum.ExecTransType_NEW),
-		field.NewExecType(enum.ExecType(status)),
-		field.NewOrdStatus(status),
-		field.NewSymbol(order.Symbol),
-		field.NewSide(order.Side),
-		field.NewLeavesQty(order.OpenQuantity(), 2),
-		field.NewCumQty(order.ExecutedQuantity, 2),
-		field.NewAvgPx(order.AvgPx, 2),
-	)
-	execReport.SetOrderQty(order.Quantity, 2)
-	execReport.SetClOrdID(order.ClOrdID)
-
-	switch status {
-	case enum.OrdStatus_FILLED, enum.OrdStatus_PARTIALLY_FILLED:
-		execReport.SetLastShares(order.LastExecutedQuantity, 2)
-		execReport.SetLastPx(order.LastExecutedPrice, 2)
-	}
-
-	execReport.Header.SetTargetCompID(order.SenderCompID)
-	execReport.Header.SetSenderCompID(order.TargetCompID)
-
-	sendErr := quickfix.Send(execReport)
+	a.report(order, enum.ExecType(status), status)
+}
+
+// report sends order the ExecutionReport of execType and status
+func (a *Application) report(order internal.Order, execType enum.ExecType, status enum.OrdStatus) {
+	execReport, err := a.newExecutionReport(order, execType, status)
+	if err != nil {
+		a.stop(err)
+		return
+	}
+	a.sendExecutionReport(order, execReport)
+}
+
+// stop shuts the service down once the journal has failed, so no change it may have missed is
+// reported and the book restored on restart is the one firms were told of
+func (a *Application) stop(err error) {
+	utils.PrintBad(fmt.Sprintf("stopping: %s", err))
+	select {
+	case a.stopped <- err:
+	default:
+	}
+}
+
+func (a *Application) sendExecutionReport(order internal.Order, execReport *quickfix.Message) {
+	sendErr := quickfix.SendToTarget(execReport, sessionOf(order))
 	if sendErr != nil {
 		fmt.Println(sendErr)
 	}
-
 }
 
 const (
 	usage = "ordermatch"
 	short = "Start an order matching (FIX acceptor) service"
 	long  = "Start an order matching (FIX acceptor) service."
+
+	// journalPathSetting names the file the order book is journaled to
+	journalPathSetting = "JournalPath"
+
+	// riskLimitsPathSetting names the file holding the pre-trade risk limits
+	riskLimitsPathSetting = "RiskLimitsPath"
+
+	// selfTradePreventionSetting picks what happens to crossing orders of the same firm
+	selfTradePreventionSetting = "SelfTradePrevention"
+
+	// dropCopySetting flags a session that receives a TradeCaptureReport of every trade
+	dropCopySetting = "DropCopy"
+
+	// instrumentsPathSetting names the instrument master, the symbols that may be traded
+	instrumentsPathSetting = "InstrumentsPath"
+
+	// tradingSchedulePathSetting names the daily timetable of trading phases
+	tradingSchedulePathSetting = "TradingSchedulePath"
+
+	// adminAddressSetting names the host:port the admin API listens on
+	adminAddressSetting = "AdminAddress"
 )
 
 var (
@@ -248,8 +779,15 @@ var (
 		Example: "qf ordermatch [YOUR_FIX_CONFIG_FILE_HERE.cfg] (default is ./config/ordermatch.cfg)",
 		RunE:    execute,
 	}
+
+	// storeF overrides the message store picked by the config file.
+	storeF string
 )
 
+func init() {
+	Cmd.Flags().StringVar(&storeF, "store", "", "message store to use: memory, file or sql (default picked from the config file)")
+}
+
 func execute(_ *cobra.Command, args []string) error {
 	var cfgFileName string
 	argLen := len(args)
@@ -288,8 +826,124 @@ func execute(_ *cobra.Command, args []string) error {
 	logger := utils.NewFancyLog()
 	app := newApplication()
 
+	// the book survives a restart when it is journaled
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(journalPathSetting) {
+		journalPath, err := settings.Setting(journalPathSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		if err := app.OpenJournal(journalPath); err != nil {
+			return fmt.Errorf("unable to open journal %v: %s", journalPath, err)
+		}
+		utils.PrintInfo(fmt.Sprintf("order book restored from %v", journalPath))
+	}
+
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(riskLimitsPathSetting) {
+		riskLimitsPath, err := settings.Setting(riskLimitsPathSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		limits, err := internal.LoadRiskLimits(riskLimitsPath)
+		if err != nil {
+			return fmt.Errorf("unable to load risk limits %v: %s", riskLimitsPath, err)
+		}
+		app.SetRiskLimits(limits)
+	}
+
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(selfTradePreventionSetting) {
+		value, err := settings.Setting(selfTradePreventionSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		stp, err := internal.ParseSelfTradePrevention(value)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+		app.SetSelfTradePrevention(stp)
+	}
+
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(instrumentsPathSetting) {
+		instrumentsPath, err := settings.Setting(instrumentsPathSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		master, err := instruments.Load(instrumentsPath)
+		if err != nil {
+			return fmt.Errorf("unable to load instruments %v: %s", instrumentsPath, err)
+		}
+		app.instruments = master
+		master.AddRoutes(app.MessageRouter)
+	}
+
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(tradingSchedulePathSetting) {
+		schedulePath, err := settings.Setting(tradingSchedulePathSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		schedule, err := internal.LoadSchedule(schedulePath)
+		if err != nil {
+			return fmt.Errorf("unable to load trading schedule %v: %s", schedulePath, err)
+		}
+		app.phases.SetSchedule(schedule, time.Now())
+		utils.PrintInfo(fmt.Sprintf("market is %v", app.phases.Market()))
+
+		go app.runSchedule()
+	}
+
+	for sessionID, settings := range appSettings.SessionSettings() {
+		if !settings.HasSetting(dropCopySetting) {
+			continue
+		}
+
+		dropCopy, err := settings.BoolSetting(dropCopySetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+		if !dropCopy {
+			continue
+		}
+
+		// trade capture reports need FIX.4.4 or later
+		switch sessionID.BeginString {
+		case quickfix.BeginStringFIX44, quickfix.BeginStringFIXT11:
+		default:
+			return fmt.Errorf("drop copy session %v must be FIX.4.4 or FIXT.1.1", sessionID)
+		}
+		app.dropCopies[sessionID] = true
+	}
+
+	if settings := appSettings.GlobalSettings(); settings.HasSetting(adminAddressSetting) {
+		address, err := settings.Setting(adminAddressSetting)
+		if err != nil {
+			return fmt.Errorf("error reading cfg: %s,", err)
+		}
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("unable to start admin API on %v: %s", address, err)
+		}
+
+		server := &http.Server{Handler: app.adminHandler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				fmt.Println(err)
+			}
+		}()
+		utils.PrintInfo(fmt.Sprintf("admin API listening on %v", listener.Addr()))
+	}
+
+	storeFactory, err := utils.NewStoreFactory(appSettings, storeF)
+	if err != nil {
+		return fmt.Errorf("unable to create message store: %s", err)
+	}
+
 	utils.PrintConfig("acceptor", bytes.NewReader(stringData))
-	acceptor, err := quickfix.NewAcceptor(app, quickfix.NewMemoryStoreFactory(), appSettings, logger)
+	acceptor, err := quickfix.NewAcceptor(app, storeFactory, appSettings, logger)
 	if err != nil {
 		return fmt.Errorf("unable to create acceptor: %s", err)
 	}
@@ -302,18 +956,52 @@ func execute(_ *cobra.Command, args []string) error {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-interrupt
+		code := 0
+		select {
+		case <-interrupt:
+		case <-app.stopped:
+			code = 1
+		}
 		acceptor.Stop()
-		os.Exit(0)
+		_ = app.CloseJournal()
+		os.Exit(code)
 	}()
 
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
 		scanner.Scan()
 
-		switch value := scanner.Text(); value {
-		case "#symbols":
+		switch value := scanner.Text(); {
+		case value == "#symbols":
 			app.Display()
+		case strings.HasPrefix(value, "#fills"):
+			args := strings.Fields(value)
+			if len(args) != 3 {
+				utils.PrintBad("usage: #fills SYMBOL ORDERID")
+				continue
+			}
+			app.DisplayFills(args[1], args[2])
+		case strings.HasPrefix(value, "#halt"), strings.HasPrefix(value, "#resume"):
+			args := strings.Fields(value)
+			if len(args) > 2 {
+				utils.PrintBad("usage: #halt [SYMBOL] or #resume [SYMBOL]")
+				continue
+			}
+
+			var symbol string
+			if len(args) == 2 {
+				symbol = args[1]
+			}
+
+			change, done := app.halt, "halted"
+			if args[0] == "#resume" {
+				change, done = app.resume, "resumed"
+			}
+			if err := change(symbol); err != nil {
+				utils.PrintBad(err.Error())
+				continue
+			}
+			utils.PrintInfo(fmt.Sprintf("%v %v", tradingScope(symbol), done))
 		default:
 			app.DisplayMarket(value)
 		}
@@ -0,0 +1,50 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+)
+
+func TestOrdRejReason(t *testing.T) {
+	tests := []struct {
+		reason      enum.OrdRejReason
+		beginString string
+		applVerID   enum.ApplVerID
+		want        enum.OrdRejReason
+	}{
+		{enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND, quickfix.BeginStringFIX44, "", enum.OrdRejReason_ORDER_EXCEEDS_LIMIT},
+		{enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND, quickfix.BeginStringFIXT11, enum.ApplVerID_FIX50, enum.OrdRejReason_ORDER_EXCEEDS_LIMIT},
+		{enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND, quickfix.BeginStringFIXT11, enum.ApplVerID_FIX50SP1, enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND},
+		{enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND, quickfix.BeginStringFIXT11, enum.ApplVerID_FIX50SP2, enum.OrdRejReason_PRICE_EXCEEDS_CURRENT_PRICE_BAND},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIX43, "", enum.OrdRejReason_BROKER},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIX44, "", enum.OrdRejReason_INCORRECT_QUANTITY},
+		{enum.OrdRejReason_INCORRECT_QUANTITY, quickfix.BeginStringFIXT11, enum.ApplVerID_FIX50, enum.OrdRejReason_INCORRECT_QUANTITY},
+		{enum.OrdRejReason_DUPLICATE_ORDER, quickfix.BeginStringFIX40, "", enum.OrdRejReason_BROKER},
+		{enum.OrdRejReason_DUPLICATE_ORDER, quickfix.BeginStringFIX41, "", enum.OrdRejReason_DUPLICATE_ORDER},
+		{enum.OrdRejReason_DUPLICATE_ORDER, quickfix.BeginStringFIX42, "", enum.OrdRejReason_DUPLICATE_ORDER},
+		{enum.OrdRejReason_EXCHANGE_CLOSED, quickfix.BeginStringFIX40, "", enum.OrdRejReason_EXCHANGE_CLOSED},
+	}
+
+	for _, test := range tests {
+		if got := ordRejReason(test.reason, test.beginString, test.applVerID); got != test.want {
+			t.Errorf("%v on %v %v: got %v, want %v", test.reason, test.beginString, test.applVerID, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/tag"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix44tcrr "github.com/quickfixgo/fix44/tradecapturereportrequest"
+	fix50tcrr "github.com/quickfixgo/fix50/tradecapturereportrequest"
+)
+
+// tradeDateFormat is the layout of TradeDate, a UTC date
+const tradeDateFormat = "20060102"
+
+// tradeCaptureReportRequest is the part of TradeCaptureReportRequest common to FIX 4.4 and later
+type tradeCaptureReportRequest interface {
+	GetTradeRequestID() (string, quickfix.MessageRejectError)
+	GetTradeRequestType() (enum.TradeRequestType, quickfix.MessageRejectError)
+	HasSymbol() bool
+	GetSymbol() (string, quickfix.MessageRejectError)
+}
+
+// The repeating groups of outgoing trade capture reports. Field order within the groups is
+// the same for FIX 4.4 and FIX 5.0.
+var (
+	tradeParties = quickfix.GroupTemplate{
+		quickfix.GroupElement(tag.PartyID),
+		quickfix.GroupElement(tag.PartyIDSource),
+		quickfix.GroupElement(tag.PartyRole),
+	}
+
+	tradeSides = quickfix.GroupTemplate{
+		quickfix.GroupElement(tag.Side),
+		quickfix.GroupElement(tag.OrderID),
+		quickfix.GroupElement(tag.ClOrdID),
+		quickfix.NewRepeatingGroup(tag.NoPartyIDs, tradeParties),
+		quickfix.GroupElement(tag.AggressorIndicator),
+	}
+)
+
+// tradeRequest identifies the TradeCaptureReportRequest being answered
+type tradeRequest struct {
+	tradeRequestID string
+	requestType    enum.TradeRequestType
+	symbol         string
+}
+
+// isDropCopy returns true if sessionID receives a copy of every trade
+func (a *Application) isDropCopy(sessionID quickfix.SessionID) bool {
+	return a.dropCopies[sessionID]
+}
+
+// newTradeCaptureReport builds a TradeCaptureReport of trade, with both sides, for a session
+// of FIX version beginString
+func newTradeCaptureReport(trade internal.Trade, beginString string) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_TRADE_CAPTURE_REPORT))
+	msg.Body.Set(field.NewTradeReportID(trade.TradeID))
+	msg.Body.Set(field.NewTrdMatchID(trade.TradeID))
+	msg.Body.Set(field.NewPreviouslyReported(false))
+	msg.Body.Set(field.NewSymbol(trade.Symbol))
+	msg.Body.Set(field.NewLastQty(trade.Quantity, 2))
+	msg.Body.Set(field.NewLastPx(trade.Price, 2))
+	msg.Body.Set(field.NewTradeDate(trade.Time.UTC().Format(tradeDateFormat)))
+	msg.Body.Set(field.NewTransactTime(trade.Time))
+
+	isFIX50 := beginString == quickfix.BeginStringFIXT11
+	if isFIX50 {
+		msg.Body.Set(field.NewTradeID(trade.TradeID))
+	}
+
+	sides := quickfix.NewRepeatingGroup(tag.NoSides, tradeSides)
+	for _, order := range []internal.Order{trade.Aggressor, trade.Resting} {
+		side := sides.Add()
+		side.Set(field.NewSide(order.Side))
+		side.Set(field.NewOrderID(order.OrderID))
+		side.Set(field.NewClOrdID(order.ClOrdID))
+
+		parties := quickfix.NewRepeatingGroup(tag.NoPartyIDs, tradeParties)
+		party := parties.Add()
+		party.Set(field.NewPartyID(order.SenderCompID))
+		party.Set(field.NewPartyIDSource(enum.PartyIDSource_PROPRIETARY))
+		party.Set(field.NewPartyRole(enum.PartyRole_EXECUTING_FIRM))
+		side.SetGroup(parties)
+
+		if isFIX50 {
+			side.Set(field.NewAggressorIndicator(!trade.Auction && order.OrderID == trade.Aggressor.OrderID))
+		}
+	}
+	msg.Body.SetGroup(sides)
+
+	return msg
+}
+
+// publishTrades sends a TradeCaptureReport of each trade to every drop copy session
+func (a *Application) publishTrades(trades []internal.Trade) {
+	for _, trade := range trades {
+		for sessionID := range a.dropCopies {
+			if err := quickfix.SendToTarget(newTradeCaptureReport(trade, sessionID.BeginString), sessionID); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func (a *Application) onFIX44TradeCaptureReportRequest(msg fix44tcrr.TradeCaptureReportRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	var dates *quickfix.RepeatingGroup
+	if msg.HasNoDates() {
+		group, err := msg.GetNoDates()
+		if err != nil {
+			return err
+		}
+		dates = group.RepeatingGroup
+	}
+
+	return a.onTradeCaptureReportRequest(msg, dates, sessionID)
+}
+
+func (a *Application) onFIX50TradeCaptureReportRequest(msg fix50tcrr.TradeCaptureReportRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	var dates *quickfix.RepeatingGroup
+	if msg.HasNoDates() {
+		group, err := msg.GetNoDates()
+		if err != nil {
+			return err
+		}
+		dates = group.RepeatingGroup
+	}
+
+	return a.onTradeCaptureReportRequest(msg, dates, sessionID)
+}
+
+// onTradeCaptureReportRequest answers a drop copy session with the trades it asks for, all
+// trades since the first entry of dates, up to the second entry if there is one
+func (a *Application) onTradeCaptureReportRequest(msg tradeCaptureReportRequest, dates *quickfix.RepeatingGroup, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	tradeRequestID, err := msg.GetTradeRequestID()
+	if err != nil {
+		return err
+	}
+
+	requestType, err := msg.GetTradeRequestType()
+	if err != nil {
+		return err
+	}
+
+	var symbol string
+	if msg.HasSymbol() {
+		if symbol, err = msg.GetSymbol(); err != nil {
+			return err
+		}
+	}
+
+	req := tradeRequest{tradeRequestID: tradeRequestID, requestType: requestType, symbol: symbol}
+
+	if !a.isDropCopy(sessionID) {
+		a.ackTradeRequest(req, enum.TradeRequestResult_NOT_AUTHORIZED, 0, "trades are only reported to drop copy sessions", sessionID)
+		return nil
+	}
+
+	if requestType != enum.TradeRequestType_ALL_TRADES {
+		a.ackTradeRequest(req, enum.TradeRequestResult_TRADEREQUESTTYPE_NOT_SUPPORTED, 0, "only all trades may be requested", sessionID)
+		return nil
+	}
+
+	from, to, err := tradeDates(dates)
+	if err != nil {
+		return err
+	}
+
+	trades := a.Trades(symbol, from, to)
+	a.ackTradeRequest(req, enum.TradeRequestResult_SUCCESSFUL, len(trades), "", sessionID)
+
+	for i, trade := range trades {
+		report := newTradeCaptureReport(trade, sessionID.BeginString)
+		report.Body.Set(field.NewTradeRequestID(tradeRequestID))
+		report.Body.Set(field.NewPreviouslyReported(true))
+		report.Body.Set(field.NewTotNumTradeReports(len(trades)))
+		report.Body.Set(field.NewLastRptRequested(i == len(trades)-1))
+
+		if err := quickfix.SendToTarget(report, sessionID); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// tradeDates returns the period the NoDates entries of a request cover. Each entry is a
+// TransactTime or else a whole TradeDate; a missing entry leaves that end open.
+func tradeDates(dates *quickfix.RepeatingGroup) (from, to time.Time, err quickfix.MessageRejectError) {
+	if dates == nil {
+		return
+	}
+
+	for i := 0; i < dates.Len() && i < 2; i++ {
+		entry := dates.Get(i)
+
+		var t time.Time
+		switch {
+		case entry.Has(tag.TransactTime):
+			var transactTime field.TransactTimeField
+			if err = entry.Get(&transactTime); err != nil {
+				return
+			}
+			t = transactTime.Value()
+
+		case entry.Has(tag.TradeDate):
+			var tradeDate field.TradeDateField
+			if err = entry.Get(&tradeDate); err != nil {
+				return
+			}
+
+			var parseErr error
+			if t, parseErr = time.Parse(tradeDateFormat, tradeDate.Value()); parseErr != nil {
+				err = quickfix.IncorrectDataFormatForValue(tag.TradeDate)
+				return
+			}
+			if i == 1 {
+				t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+
+		default:
+			continue
+		}
+
+		if i == 0 {
+			from = t
+		} else {
+			to = t
+		}
+	}
+
+	return
+}
+
+// ackTradeRequest answers req with a TradeCaptureReportRequestAck, reporting how many trades
+// follow if it was successful
+func (a *Application) ackTradeRequest(req tradeRequest, result enum.TradeRequestResult, trades int, text string, sessionID quickfix.SessionID) {
+	status := enum.TradeRequestStatus_COMPLETED
+	if result != enum.TradeRequestResult_SUCCESSFUL {
+		status = enum.TradeRequestStatus_REJECTED
+	}
+
+	// the ack carries the instrument asked for, or none
+	symbol := req.symbol
+	if symbol == "" {
+		symbol = "[N/A]"
+	}
+
+	ack := quickfix.NewMessage()
+	ack.Header.Set(field.NewMsgType(enum.MsgType_TRADE_CAPTURE_REPORT_REQUEST_ACK))
+	ack.Body.Set(field.NewTradeRequestID(req.tradeRequestID))
+	ack.Body.Set(field.NewTradeRequestType(req.requestType))
+	ack.Body.Set(field.NewTradeRequestResult(result))
+	ack.Body.Set(field.NewTradeRequestStatus(status))
+	ack.Body.Set(field.NewSymbol(symbol))
+	if result == enum.TradeRequestResult_SUCCESSFUL {
+		ack.Body.Set(field.NewTotNumTradeReports(trades))
+	}
+	if text != "" {
+		ack.Body.Set(field.NewText(text))
+	}
+
+	if err := quickfix.SendToTarget(ack, sessionID); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package ordermatch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/internal/messages"
+	"github.com/quickfixgo/examples/cmd/ordermatch/internal"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/tag"
+
+	"github.com/quickfixgo/quickfix"
+
+	fix42tssr "github.com/quickfixgo/fix42/tradingsessionstatusrequest"
+	fix43tssr "github.com/quickfixgo/fix43/tradingsessionstatusrequest"
+	fix44tssr "github.com/quickfixgo/fix44/tradingsessionstatusrequest"
+	fix50tssr "github.com/quickfixgo/fix50/tradingsessionstatusrequest"
+)
+
+// tradingSessionID is the single trading session the matcher runs
+const tradingSessionID = enum.TradingSessionID_DAY
+
+// tradingSessionStatusRequest is the part of TradingSessionStatusRequest common to every supported FIX version
+type tradingSessionStatusRequest interface {
+	GetTradSesReqID() (string, quickfix.MessageRejectError)
+	GetSubscriptionRequestType() (enum.SubscriptionRequestType, quickfix.MessageRejectError)
+	HasTradingSessionID() bool
+	GetTradingSessionID() (enum.TradingSessionID, quickfix.MessageRejectError)
+}
+
+func (a *Application) addTradingSessionRoutes() {
+	a.AddRoute(fix42tssr.Route(messages.Route[fix42tssr.TradingSessionStatusRequest](a.onTradingSessionStatusRequest)))
+	a.AddRoute(fix43tssr.Route(messages.Route[fix43tssr.TradingSessionStatusRequest](a.onTradingSessionStatusRequest)))
+	a.AddRoute(fix44tssr.Route(messages.Route[fix44tssr.TradingSessionStatusRequest](a.onTradingSessionStatusRequest)))
+	a.AddRoute(fix50tssr.Route(messages.Route[fix50tssr.TradingSessionStatusRequest](a.onTradingSessionStatusRequest)))
+}
+
+// tradSesStatus returns the TradSesStatus reporting phase
+func tradSesStatus(phase internal.Phase) enum.TradSesStatus {
+	switch phase {
+	case internal.PhaseHalted:
+		return enum.TradSesStatus_HALTED
+	case internal.PhaseContinuous:
+		return enum.TradSesStatus_OPEN
+	case internal.PhasePreOpen, internal.PhaseOpeningAuction:
+		return enum.TradSesStatus_PRE_OPEN
+	case internal.PhaseClosingAuction:
+		return enum.TradSesStatus_PRE_CLOSE
+	}
+
+	return enum.TradSesStatus_CLOSED
+}
+
+// securityTradingStatus returns the SecurityTradingStatus reporting a symbol moving from
+// phase previous to phase in the FIX version beginString
+func securityTradingStatus(phase, previous internal.Phase, beginString string) enum.SecurityTradingStatus {
+	switch phase {
+	case internal.PhaseHalted:
+		return enum.SecurityTradingStatus_TRADING_HALT
+	case internal.PhaseContinuous:
+		if previous == internal.PhaseHalted {
+			return enum.SecurityTradingStatus_RESUME
+		}
+		return enum.SecurityTradingStatus_READY_TO_TRADE
+	case internal.PhasePreOpen, internal.PhaseOpeningAuction:
+		// only defined from FIX 4.3 on
+		if beginString == quickfix.BeginStringFIX42 {
+			return enum.SecurityTradingStatus_OPENING_DELAY
+		}
+		if phase == internal.PhasePreOpen {
+			return enum.SecurityTradingStatus_PRE_OPEN
+		}
+		return enum.SecurityTradingStatus_OPENING_ROTATION
+	}
+
+	return enum.SecurityTradingStatus_NOT_AVAILABLE_FOR_TRADING
+}
+
+// newTradingSessionStatus builds the TradingSessionStatus reporting the market in phase
+func newTradingSessionStatus(phase internal.Phase, unsolicited bool) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_TRADING_SESSION_STATUS))
+	msg.Body.Set(field.NewTradingSessionID(tradingSessionID))
+	msg.Body.Set(field.NewTradSesStatus(tradSesStatus(phase)))
+	msg.Body.Set(field.NewUnsolicitedIndicator(unsolicited))
+	msg.Body.Set(field.NewText(string(phase)))
+
+	return msg
+}
+
+// newSecurityStatus builds the SecurityStatus reporting symbol moving from phase previous to
+// phase, for a session of FIX version beginString
+func newSecurityStatus(symbol string, phase, previous internal.Phase, beginString string) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(enum.MsgType_SECURITY_STATUS))
+	msg.Body.Set(field.NewSymbol(symbol))
+	msg.Body.Set(field.NewTradingSessionID(tradingSessionID))
+	msg.Body.Set(field.NewUnsolicitedIndicator(true))
+	msg.Body.Set(field.NewSecurityTradingStatus(securityTradingStatus(phase, previous, beginString)))
+	msg.Body.Set(field.NewTransactTime(time.Now()))
+
+	// FIX 4.2 security status has no Text
+	if beginString != quickfix.BeginStringFIX42 {
+		msg.Body.Set(field.NewText(string(phase)))
+	}
+
+	return msg
+}
+
+// broadcast sends the message build returns for each logged on session of FIX 4.2 or later,
+// the first version with trading session and security status
+func (a *Application) broadcast(build func(beginString string) *quickfix.Message) {
+	for sessionID, loggedOn := range a.sessions {
+		if !loggedOn {
+			continue
+		}
+
+		switch sessionID.BeginString {
+		case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41:
+			continue
+		}
+
+		if err := quickfix.SendToTarget(build(sessionID.BeginString), sessionID); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// changePhases applies change to the trading phases, then reports the market phase if it
+// moved, and the phase of each symbol keeping its own phase that moved. Books leaving a call
+// auction are uncrossed, books entering continuous trading are matched, and books entering a
+// call auction publish where it would uncross.
+func (a *Application) changePhases(change func(phases *internal.Phases)) {
+	market := a.phases.Market()
+	before := make(map[string]internal.Phase)
+	for _, symbol := range append(a.Symbols(), a.phases.Own()...) {
+		before[symbol] = a.phases.Of(symbol)
+	}
+
+	own := make(map[string]bool)
+	for _, symbol := range a.phases.Own() {
+		own[symbol] = true
+	}
+
+	change(a.phases)
+
+	// a symbol taking a phase of its own was in the phase of the market
+	for _, symbol := range a.phases.Own() {
+		own[symbol] = true
+		if _, ok := before[symbol]; !ok {
+			before[symbol] = market
+		}
+	}
+
+	if phase := a.phases.Market(); phase != market {
+		a.broadcast(func(string) *quickfix.Message { return newTradingSessionStatus(phase, true) })
+	}
+
+	for symbol, previous := range before {
+		phase := a.phases.Of(symbol)
+		if phase == previous {
+			continue
+		}
+
+		if own[symbol] {
+			a.broadcast(func(beginString string) *quickfix.Message {
+				return newSecurityStatus(symbol, phase, previous, beginString)
+			})
+		}
+
+		if previous.Auction() {
+			delete(a.indications, symbol)
+
+			// a halt keeps the call open until trading resumes
+			if phase != internal.PhaseHalted {
+				trades := a.uncross(symbol)
+				a.publishMarketData(symbol, trades)
+			}
+		}
+
+		if phase.Matching() && !previous.Matching() {
+			trades := a.match(symbol)
+			a.publishMarketData(symbol, trades)
+		}
+
+		a.publishIndication(symbol)
+	}
+}
+
+// runSchedule moves the trading phases along the schedule, checking every second
+func (a *Application) runSchedule() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		a.mu.Lock()
+		a.changePhases(func(phases *internal.Phases) { phases.Update(now) })
+		a.mu.Unlock()
+	}
+}
+
+// tradingScope names symbol, or the market when symbol is empty
+func tradingScope(symbol string) string {
+	if symbol == "" {
+		return "market"
+	}
+
+	return symbol
+}
+
+// halt stops trading in symbol, or in the whole market when symbol is empty
+func (a *Application) halt(symbol string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	halted := false
+	a.changePhases(func(phases *internal.Phases) { halted = phases.Halt(symbol) })
+	if !halted {
+		return fmt.Errorf("%v is already halted", tradingScope(symbol))
+	}
+
+	return nil
+}
+
+// resume lifts the halt of symbol, or of the whole market when symbol is empty
+func (a *Application) resume(symbol string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	resumed := false
+	a.changePhases(func(phases *internal.Phases) { resumed = phases.Resume(symbol) })
+	if !resumed {
+		return fmt.Errorf("%v is not halted", tradingScope(symbol))
+	}
+
+	return nil
+}
+
+// onTradingSessionStatusRequest answers with the current phase of the market. Every logged
+// on session is sent the changes that follow whatever it subscribed to.
+func (a *Application) onTradingSessionStatusRequest(msg tradingSessionStatusRequest, sessionID quickfix.SessionID) quickfix.MessageRejectError {
+	tradSesReqID, err := msg.GetTradSesReqID()
+	if err != nil {
+		return err
+	}
+
+	subscriptionRequestType, err := msg.GetSubscriptionRequestType()
+	if err != nil {
+		return err
+	}
+	if subscriptionRequestType == enum.SubscriptionRequestType_DISABLE_PREVIOUS_SNAPSHOT_PLUS_UPDATE_REQUEST {
+		return nil
+	}
+
+	status := newTradingSessionStatus(a.phases.Market(), false)
+	status.Body.Set(field.NewTradSesReqID(tradSesReqID))
+
+	if msg.HasTradingSessionID() {
+		requested, err := msg.GetTradingSessionID()
+		if err != nil {
+			return err
+		}
+
+		if requested != tradingSessionID {
+			// a rejected request is only reported from FIX 4.3 on
+			if sessionID.BeginString == quickfix.BeginStringFIX42 {
+				return quickfix.ValueIsIncorrect(tag.TradingSessionID)
+			}
+
+			status.Body.Set(field.NewTradingSessionID(requested))
+			status.Body.Set(field.NewTradSesStatus(enum.TradSesStatus_REQUEST_REJECTED))
+			status.Body.Set(field.NewTradSesStatusRejReason(enum.TradSesStatusRejReason_UNKNOWN_OR_INVALID_TRADINGSESSIONID))
+			status.Body.Set(field.NewText(fmt.Sprintf("unknown trading session %v", requested)))
+		}
+	}
+
+	if err := quickfix.SendToTarget(status, sessionID); err != nil {
+		fmt.Println(err)
+	}
+
+	return nil
+}
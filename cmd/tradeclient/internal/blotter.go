@@ -0,0 +1,325 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gosuri/uitable"
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
+	"github.com/shopspring/decimal"
+)
+
+// statusPendingNew is shown for an order the counter party has not acknowledged yet
+const statusPendingNew = enum.OrdStatus_PENDING_NEW
+
+var statusNames = map[enum.OrdStatus]string{
+	enum.OrdStatus_NEW:              "New",
+	enum.OrdStatus_PARTIALLY_FILLED: "Partially Filled",
+	enum.OrdStatus_FILLED:           "Filled",
+	enum.OrdStatus_DONE_FOR_DAY:     "Done For Day",
+	enum.OrdStatus_CANCELED:         "Canceled",
+	enum.OrdStatus_REPLACED:         "Replaced",
+	enum.OrdStatus_PENDING_CANCEL:   "Pending Cancel",
+	enum.OrdStatus_STOPPED:          "Stopped",
+	enum.OrdStatus_REJECTED:         "Rejected",
+	enum.OrdStatus_SUSPENDED:        "Suspended",
+	enum.OrdStatus_PENDING_NEW:      "Pending New",
+	enum.OrdStatus_EXPIRED:          "Expired",
+	enum.OrdStatus_PENDING_REPLACE:  "Pending Replace",
+}
+
+var sideNames = map[enum.Side]string{
+	enum.Side_BUY:               "Buy",
+	enum.Side_SELL:              "Sell",
+	enum.Side_SELL_SHORT:        "Sell Short",
+	enum.Side_SELL_SHORT_EXEMPT: "Sell Short Exempt",
+	enum.Side_CROSS:             "Cross",
+	enum.Side_CROSS_SHORT:       "Cross Short",
+}
+
+// BlotterOrder is the client's view of an order it sent
+type BlotterOrder struct {
+	ClOrdID     string
+	OrigClOrdID string
+	OrderID     string
+	Symbol      string
+	Side        enum.Side
+	OrdType     enum.OrdType
+	Price       decimal.Decimal
+	Quantity    decimal.Decimal
+	CumQty      decimal.Decimal
+	AvgPx       decimal.Decimal
+	Status      enum.OrdStatus
+	SessionID   quickfix.SessionID
+
+	// ClOrdID of a cancel or cancel/replace sent for the order and not answered yet
+	Pending string
+
+	// orders are listed in the order they were entered
+	entered int
+}
+
+// IsOpen returns true while the order can still trade
+func (o BlotterOrder) IsOpen() bool {
+	switch o.Status {
+	case enum.OrdStatus_FILLED, enum.OrdStatus_CANCELED, enum.OrdStatus_REJECTED, enum.OrdStatus_EXPIRED, enum.OrdStatus_DONE_FOR_DAY:
+		return false
+	}
+
+	return true
+}
+
+// LeavesQty returns the quantity of the order still open
+func (o BlotterOrder) LeavesQty() decimal.Decimal {
+	if !o.IsOpen() {
+		return decimal.Zero
+	}
+
+	return o.Quantity.Sub(o.CumQty)
+}
+
+// Blotter tracks the orders the client sends, keyed by their current ClOrdID, from the
+// requests going out and the execution reports and cancel rejects coming back
+type Blotter struct {
+	mu      sync.Mutex
+	orders  map[string]*BlotterOrder
+	entered int
+
+	// each unanswered cancel or cancel/replace, by its ClOrdID
+	pending map[string]pendingRequest
+}
+
+// pendingRequest is a cancel or cancel/replace waiting for an answer
+type pendingRequest struct {
+	origClOrdID string
+
+	// the amended terms of a cancel/replace, nil for a cancel
+	price, quantity *decimal.Decimal
+}
+
+// NewBlotter returns an empty Blotter
+func NewBlotter() *Blotter {
+	return &Blotter{orders: make(map[string]*BlotterOrder), pending: make(map[string]pendingRequest)}
+}
+
+// OnSend records an outgoing NewOrderSingle, OrderCancelRequest or OrderCancelReplaceRequest
+func (b *Blotter) OnSend(msg *quickfix.Message, sessionID quickfix.SessionID) {
+	msgType, err := msg.MsgType()
+	if err != nil {
+		return
+	}
+
+	clOrdID, _ := msg.Body.GetString(tag.ClOrdID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch enum.MsgType(msgType) {
+	case enum.MsgType_ORDER_SINGLE:
+		if _, ok := b.orders[clOrdID]; ok {
+			return
+		}
+
+		b.entered++
+		order := &BlotterOrder{ClOrdID: clOrdID, Status: statusPendingNew, SessionID: sessionID, entered: b.entered}
+		order.Symbol, _ = msg.Body.GetString(tag.Symbol)
+
+		var side field.SideField
+		if msg.Body.Get(&side) == nil {
+			order.Side = side.Value()
+		}
+		var ordType field.OrdTypeField
+		if msg.Body.Get(&ordType) == nil {
+			order.OrdType = ordType.Value()
+		}
+		var price field.PriceField
+		if msg.Body.Get(&price) == nil {
+			order.Price = price.Value()
+		}
+		var orderQty field.OrderQtyField
+		if msg.Body.Get(&orderQty) == nil {
+			order.Quantity = orderQty.Value()
+		}
+
+		b.orders[clOrdID] = order
+
+	case enum.MsgType_ORDER_CANCEL_REQUEST, enum.MsgType_ORDER_CANCEL_REPLACE_REQUEST:
+		origClOrdID, _ := msg.Body.GetString(tag.OrigClOrdID)
+		order, ok := b.orders[origClOrdID]
+		if !ok {
+			return
+		}
+
+		req := pendingRequest{origClOrdID: origClOrdID}
+		if enum.MsgType(msgType) == enum.MsgType_ORDER_CANCEL_REPLACE_REQUEST {
+			var price field.PriceField
+			if msg.Body.Get(&price) == nil {
+				value := price.Value()
+				req.price = &value
+			}
+			var orderQty field.OrderQtyField
+			if msg.Body.Get(&orderQty) == nil {
+				value := orderQty.Value()
+				req.quantity = &value
+			}
+		}
+
+		order.Pending = clOrdID
+		b.pending[clOrdID] = req
+	}
+}
+
+// OnReceive applies an incoming ExecutionReport or OrderCancelReject to the order it is for
+func (b *Blotter) OnReceive(msg *quickfix.Message) {
+	msgType, err := msg.MsgType()
+	if err != nil {
+		return
+	}
+
+	clOrdID, _ := msg.Body.GetString(tag.ClOrdID)
+	origClOrdID, _ := msg.Body.GetString(tag.OrigClOrdID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch enum.MsgType(msgType) {
+	case enum.MsgType_EXECUTION_REPORT:
+		order := b.answer(clOrdID, origClOrdID)
+		if order == nil {
+			return
+		}
+
+		order.OrderID, _ = msg.Body.GetString(tag.OrderID)
+
+		var ordStatus field.OrdStatusField
+		if msg.Body.Get(&ordStatus) == nil {
+			order.Status = ordStatus.Value()
+		}
+		var cumQty field.CumQtyField
+		if msg.Body.Get(&cumQty) == nil {
+			order.CumQty = cumQty.Value()
+		}
+		var avgPx field.AvgPxField
+		if msg.Body.Get(&avgPx) == nil {
+			order.AvgPx = avgPx.Value()
+		}
+		var orderQty field.OrderQtyField
+		if msg.Body.Get(&orderQty) == nil {
+			order.Quantity = orderQty.Value()
+		}
+		var price field.PriceField
+		if msg.Body.Get(&price) == nil {
+			order.Price = price.Value()
+		}
+
+	case enum.MsgType_ORDER_CANCEL_REJECT:
+		req, ok := b.pending[clOrdID]
+		if !ok {
+			return
+		}
+		delete(b.pending, clOrdID)
+
+		order, ok := b.orders[req.origClOrdID]
+		if !ok {
+			return
+		}
+		order.Pending = ""
+
+		var ordStatus field.OrdStatusField
+		if msg.Body.Get(&ordStatus) == nil && ordStatus.Value() != enum.OrdStatus_REJECTED {
+			order.Status = ordStatus.Value()
+		}
+	}
+}
+
+// answer returns the order an execution report for clOrdID is about. A report answering a
+// cancel or cancel/replace moves the order to the ClOrdID of the request, taking on the
+// amended terms of a replace.
+func (b *Blotter) answer(clOrdID, origClOrdID string) *BlotterOrder {
+	if order, ok := b.orders[clOrdID]; ok {
+		return order
+	}
+
+	req, ok := b.pending[clOrdID]
+	if ok {
+		origClOrdID = req.origClOrdID
+		delete(b.pending, clOrdID)
+	}
+
+	order, ok := b.orders[origClOrdID]
+	if !ok {
+		return nil
+	}
+
+	delete(b.orders, origClOrdID)
+	order.OrigClOrdID = origClOrdID
+	order.ClOrdID = clOrdID
+	order.Pending = ""
+	if req.price != nil {
+		order.Price = *req.price
+	}
+	if req.quantity != nil {
+		order.Quantity = *req.quantity
+	}
+	b.orders[clOrdID] = order
+
+	return order
+}
+
+// Orders returns every order in the blotter in the order they were entered, only the open ones
+// if open is true
+func (b *Blotter) Orders(open bool) []BlotterOrder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var orders []BlotterOrder
+	for _, order := range b.orders {
+		if !open || order.IsOpen() {
+			orders = append(orders, *order)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].entered < orders[j].entered })
+	return orders
+}
+
+// Display prints orders as a numbered table
+func (b *Blotter) Display(orders []BlotterOrder) {
+	if len(orders) == 0 {
+		fmt.Println("no orders")
+		return
+	}
+
+	table := uitable.New()
+	table.AddRow("", "ClOrdID", "OrderID", "Symbol", "Side", "Price", "Qty", "CumQty", "LeavesQty", "AvgPx", "Status", "Session")
+	for i, order := range orders {
+		status := statusNames[order.Status]
+		if order.Pending != "" {
+			status += fmt.Sprintf(" (pending %v)", order.Pending)
+		}
+
+		table.AddRow(fmt.Sprintf("%v)", i+1), order.ClOrdID, order.OrderID, order.Symbol, sideNames[order.Side],
+			order.Price, order.Quantity, order.CumQty, order.LeavesQty(), order.AvgPx, status, order.SessionID)
+	}
+
+	fmt.Println(table)
+}
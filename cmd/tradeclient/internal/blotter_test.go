@@ -0,0 +1,141 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+	"github.com/shopspring/decimal"
+)
+
+var testSession = quickfix.SessionID{BeginString: quickfix.BeginStringFIX44, SenderCompID: "TW", TargetCompID: "ISLD"}
+
+// testRequest is an order, cancel or replace the client sends
+func testRequest(msgType enum.MsgType, clOrdID, origClOrdID string, quantity int64) *quickfix.Message {
+	msg := newMessage(msgType)
+	msg.Body.Set(field.NewClOrdID(clOrdID))
+	if origClOrdID != "" {
+		msg.Body.Set(field.NewOrigClOrdID(origClOrdID))
+	}
+	msg.Body.Set(field.NewSymbol("ABC"))
+	msg.Body.Set(field.NewSide(enum.Side_BUY))
+	msg.Body.Set(field.NewOrdType(enum.OrdType_LIMIT))
+	msg.Body.Set(field.NewPrice(decimal.NewFromInt(10), 2))
+	msg.Body.Set(field.NewOrderQty(decimal.NewFromInt(quantity), 2))
+
+	return msg
+}
+
+// testReport is an ExecutionReport or OrderCancelReject the client receives
+func testReport(msgType enum.MsgType, clOrdID, origClOrdID string, status enum.OrdStatus, cumQty int64) *quickfix.Message {
+	msg := newMessage(msgType)
+	msg.Body.Set(field.NewClOrdID(clOrdID))
+	if origClOrdID != "" {
+		msg.Body.Set(field.NewOrigClOrdID(origClOrdID))
+	}
+	msg.Body.Set(field.NewOrderID("1"))
+	msg.Body.Set(field.NewOrdStatus(status))
+	msg.Body.Set(field.NewCumQty(decimal.NewFromInt(cumQty), 2))
+
+	return msg
+}
+
+func TestBlotter(t *testing.T) {
+	type want struct {
+		clOrdID  string
+		status   enum.OrdStatus
+		quantity int64
+		cumQty   int64
+		pending  string
+		open     bool
+	}
+
+	// the steps run in order on one blotter, each checked against the single order it holds
+	tests := []struct {
+		name     string
+		sent     *quickfix.Message
+		received *quickfix.Message
+		want     want
+	}{
+		{
+			name: "order sent",
+			sent: testRequest(enum.MsgType_ORDER_SINGLE, "1", "", 100),
+			want: want{"1", enum.OrdStatus_PENDING_NEW, 100, 0, "", true},
+		},
+		{
+			name:     "order acknowledged",
+			received: testReport(enum.MsgType_EXECUTION_REPORT, "1", "", enum.OrdStatus_NEW, 0),
+			want:     want{"1", enum.OrdStatus_NEW, 100, 0, "", true},
+		},
+		{
+			name:     "partial fill",
+			received: testReport(enum.MsgType_EXECUTION_REPORT, "1", "", enum.OrdStatus_PARTIALLY_FILLED, 40),
+			want:     want{"1", enum.OrdStatus_PARTIALLY_FILLED, 100, 40, "", true},
+		},
+		{
+			name: "replace sent",
+			sent: testRequest(enum.MsgType_ORDER_CANCEL_REPLACE_REQUEST, "2", "1", 200),
+			want: want{"1", enum.OrdStatus_PARTIALLY_FILLED, 100, 40, "2", true},
+		},
+		{
+			name:     "replace accepted",
+			received: testReport(enum.MsgType_EXECUTION_REPORT, "2", "1", enum.OrdStatus_PARTIALLY_FILLED, 40),
+			want:     want{"2", enum.OrdStatus_PARTIALLY_FILLED, 200, 40, "", true},
+		},
+		{
+			name: "cancel sent",
+			sent: testRequest(enum.MsgType_ORDER_CANCEL_REQUEST, "3", "2", 200),
+			want: want{"2", enum.OrdStatus_PARTIALLY_FILLED, 200, 40, "3", true},
+		},
+		{
+			name:     "cancel rejected",
+			received: testReport(enum.MsgType_ORDER_CANCEL_REJECT, "3", "2", enum.OrdStatus_PARTIALLY_FILLED, 40),
+			want:     want{"2", enum.OrdStatus_PARTIALLY_FILLED, 200, 40, "", true},
+		},
+		{
+			name:     "filled",
+			received: testReport(enum.MsgType_EXECUTION_REPORT, "2", "", enum.OrdStatus_FILLED, 200),
+			want:     want{"2", enum.OrdStatus_FILLED, 200, 200, "", false},
+		},
+	}
+
+	b := NewBlotter()
+	for _, test := range tests {
+		if test.sent != nil {
+			b.OnSend(test.sent, testSession)
+		}
+		if test.received != nil {
+			b.OnReceive(test.received)
+		}
+
+		orders := b.Orders(false)
+		if len(orders) != 1 {
+			t.Fatalf("%v: %v orders, want 1", test.name, len(orders))
+		}
+
+		order := orders[0]
+		got := want{order.ClOrdID, order.Status, order.Quantity.IntPart(), order.CumQty.IntPart(), order.Pending, order.IsOpen()}
+		if got != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+		if open := len(b.Orders(true)) == 1; open != test.want.open {
+			t.Errorf("%v: listed as open %v, want %v", test.name, open, test.want.open)
+		}
+	}
+}
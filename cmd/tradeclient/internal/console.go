@@ -50,18 +50,39 @@ import (
 	fix50mdr "github.com/quickfixgo/fix50/marketdatarequest"
 )
 
-func queryString(fieldName string) string {
+// readString prompts for fieldName and returns the line entered
+func readString(fieldName string) (string, error) {
 	fmt.Printf("%v: ", fieldName)
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
-	if err := scanner.Err(); err != nil {
+	return scanner.Text(), scanner.Err()
+}
+
+// readDecimal prompts for fieldName and returns the decimal entered
+func readDecimal(fieldName string) (decimal.Decimal, error) {
+	value, err := readString(fieldName)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	val, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("Invalid %v: %v", fieldName, value)
+	}
+
+	return val, nil
+}
+
+func queryString(fieldName string) string {
+	value, err := readString(fieldName)
+	if err != nil {
 		panic(err)
 	}
-	return scanner.Text()
+	return value
 }
 
 func queryDecimal(fieldName string) decimal.Decimal {
-	val, err := decimal.NewFromString(queryString(fieldName))
+	val, err := readDecimal(fieldName)
 	if err != nil {
 		panic(err)
 	}
@@ -92,7 +113,10 @@ func QueryAction() (string, error) {
 	fmt.Println("1) Enter Order")
 	fmt.Println("2) Cancel Order")
 	fmt.Println("3) Request Market Test")
-	fmt.Println("4) Quit")
+	fmt.Println("4) List Open Orders")
+	fmt.Println("5) Cancel Open Order")
+	fmt.Println("6) Replace Open Order")
+	fmt.Println("7) Quit")
 	fmt.Print("Action: ")
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
@@ -619,3 +643,96 @@ func QueryMarketDataRequest() error {
 
 	return nil
 }
+
+// queryOpenOrder lists the open orders in blotter and returns the one picked
+func queryOpenOrder(blotter *Blotter) (BlotterOrder, error) {
+	orders := blotter.Orders(true)
+	blotter.Display(orders)
+	if len(orders) == 0 {
+		return BlotterOrder{}, fmt.Errorf("no open orders")
+	}
+
+	choiceStr, err := readString("Order")
+	if err != nil {
+		return BlotterOrder{}, err
+	}
+
+	choice, err := strconv.Atoi(choiceStr)
+	if err != nil || choice < 1 || choice > len(orders) {
+		return BlotterOrder{}, fmt.Errorf("Invalid Order: %v", choiceStr)
+	}
+
+	return orders[choice-1], nil
+}
+
+// QueryListOrders prints the open orders in blotter
+func QueryListOrders(blotter *Blotter) {
+	blotter.Display(blotter.Orders(true))
+}
+
+// QueryCancelOpenOrder cancels an order picked from the open orders in blotter
+func QueryCancelOpenOrder(blotter *Blotter) error {
+	order, err := queryOpenOrder(blotter)
+	if err != nil {
+		return err
+	}
+
+	clOrdID, err := readString("ClOrdID")
+	if err != nil {
+		return err
+	}
+
+	cxl := newOrderCancelRequest(Step{
+		ClOrdID:     clOrdID,
+		OrigClOrdID: order.ClOrdID,
+		Symbol:      order.Symbol,
+		Side:        string(order.Side),
+		Quantity:    order.Quantity,
+	}, order.SessionID.BeginString)
+
+	if queryConfirm("Send Cancel") {
+		return quickfix.SendToTarget(cxl, order.SessionID)
+	}
+
+	return nil
+}
+
+// QueryReplaceOpenOrder amends the price and quantity of an order picked from the open orders
+// in blotter. The quantity may not be less than what the order has filled already.
+func QueryReplaceOpenOrder(blotter *Blotter) error {
+	order, err := queryOpenOrder(blotter)
+	if err != nil {
+		return err
+	}
+
+	clOrdID, err := readString("ClOrdID")
+	if err != nil {
+		return err
+	}
+
+	replace := Step{
+		ClOrdID:     clOrdID,
+		OrigClOrdID: order.ClOrdID,
+		Symbol:      order.Symbol,
+		Side:        string(order.Side),
+		OrdType:     string(order.OrdType),
+	}
+	if order.OrdType != enum.OrdType_MARKET {
+		if replace.Price, err = readDecimal("Price"); err != nil {
+			return err
+		}
+	}
+
+	if replace.Quantity, err = readDecimal(fmt.Sprintf("OrderQty (%v filled)", order.CumQty)); err != nil {
+		return err
+	}
+	if replace.Quantity.LessThanOrEqual(order.CumQty) {
+		return fmt.Errorf("OrderQty %v is not above the %v filled", replace.Quantity, order.CumQty)
+	}
+
+	if queryConfirm("Send Replace") {
+		return quickfix.SendToTarget(newOrderCancelReplaceRequest(replace, order.SessionID.BeginString), order.SessionID)
+	}
+
+	return nil
+}
@@ -0,0 +1,517 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/examples/cmd/utils"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// The actions a script step can take
+const (
+	ActionOrder      = "order"
+	ActionCancel     = "cancel"
+	ActionReplace    = "replace"
+	ActionMarketData = "marketdata"
+	ActionWait       = "wait"
+	ActionSleep      = "sleep"
+)
+
+// Step is a single line of a script. Which fields apply depends on the action:
+//
+//	order:      clOrdID, symbol, side, ordType, price, stopPx, quantity, timeInForce
+//	cancel:     clOrdID, origClOrdID, symbol, side, quantity
+//	replace:    clOrdID, origClOrdID, symbol, side, ordType, price, stopPx, quantity
+//	marketdata: mdReqID, symbol, subscription
+//	wait:       msgType, clOrdID, mdReqID, ordStatus, timeout
+//	sleep:      timeout
+//
+// BeginString picks the session the step runs on, a step without one uses the session of
+// the step before it.
+type Step struct {
+	Action       string          `json:"action" yaml:"action"`
+	BeginString  string          `json:"beginString" yaml:"beginString"`
+	ClOrdID      string          `json:"clOrdID" yaml:"clOrdID"`
+	OrigClOrdID  string          `json:"origClOrdID" yaml:"origClOrdID"`
+	Symbol       string          `json:"symbol" yaml:"symbol"`
+	Side         string          `json:"side" yaml:"side"`
+	OrdType      string          `json:"ordType" yaml:"ordType"`
+	TimeInForce  string          `json:"timeInForce" yaml:"timeInForce"`
+	Price        decimal.Decimal `json:"price" yaml:"price"`
+	StopPx       decimal.Decimal `json:"stopPx" yaml:"stopPx"`
+	Quantity     decimal.Decimal `json:"quantity" yaml:"quantity"`
+	MDReqID      string          `json:"mdReqID" yaml:"mdReqID"`
+	Subscription string          `json:"subscription" yaml:"subscription"`
+	MsgType      string          `json:"msgType" yaml:"msgType"`
+	OrdStatus    string          `json:"ordStatus" yaml:"ordStatus"`
+	Timeout      string          `json:"timeout" yaml:"timeout"`
+}
+
+var sides = map[string]enum.Side{
+	"buy":        enum.Side_BUY,
+	"sell":       enum.Side_SELL,
+	"sell_short": enum.Side_SELL_SHORT,
+}
+
+var ordTypes = map[string]enum.OrdType{
+	"market":     enum.OrdType_MARKET,
+	"limit":      enum.OrdType_LIMIT,
+	"stop":       enum.OrdType_STOP,
+	"stop_limit": enum.OrdType_STOP_LIMIT,
+}
+
+var timesInForce = map[string]enum.TimeInForce{
+	"day": enum.TimeInForce_DAY,
+	"ioc": enum.TimeInForce_IMMEDIATE_OR_CANCEL,
+	"fok": enum.TimeInForce_FILL_OR_KILL,
+	"gtc": enum.TimeInForce_GOOD_TILL_CANCEL,
+}
+
+var ordStatuses = map[string]enum.OrdStatus{
+	"new":              enum.OrdStatus_NEW,
+	"partially_filled": enum.OrdStatus_PARTIALLY_FILLED,
+	"filled":           enum.OrdStatus_FILLED,
+	"canceled":         enum.OrdStatus_CANCELED,
+	"replaced":         enum.OrdStatus_REPLACED,
+	"rejected":         enum.OrdStatus_REJECTED,
+}
+
+var subscriptions = map[string]enum.SubscriptionRequestType{
+	"snapshot":  enum.SubscriptionRequestType_SNAPSHOT,
+	"subscribe": enum.SubscriptionRequestType_SNAPSHOT_PLUS_UPDATES,
+}
+
+// enumValue returns the FIX value for a name in names, or value itself if it is not a name
+func enumValue[T ~string](names map[string]T, value string) T {
+	if v, ok := names[strings.ToLower(value)]; ok {
+		return v
+	}
+
+	return T(value)
+}
+
+// LoadScript reads the steps of a script from a YAML, JSON or CSV file, picked by its extension.
+// A CSV file has a header line naming the Step fields of its columns.
+func LoadScript(fileName string) ([]Step, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var steps []Step
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(file).Decode(&steps)
+	case ".json":
+		err = json.NewDecoder(file).Decode(&steps)
+	case ".csv":
+		steps, err = readCSVSteps(file)
+	default:
+		return nil, fmt.Errorf("unknown script format %v, expected .yaml, .json or .csv", filepath.Ext(fileName))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading script %v: %s", fileName, err)
+	}
+
+	return steps, nil
+}
+
+func readCSVSteps(r io.Reader) ([]Step, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// a CSV row goes through the JSON field names so every format shares the Step definition
+	header := records[0]
+	var steps []Step
+	for i, record := range records[1:] {
+		columns := make(map[string]string)
+		for j, value := range record {
+			if j < len(header) && value != "" {
+				columns[strings.TrimSpace(header[j])] = value
+			}
+		}
+
+		data, err := json.Marshal(columns)
+		if err != nil {
+			return nil, err
+		}
+
+		var step Step
+		if err := json.Unmarshal(data, &step); err != nil {
+			return nil, fmt.Errorf("line %v: %s", i+2, err)
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// response is an application message received while a script runs
+type response struct {
+	msgType   string
+	clOrdID   string
+	mdReqID   string
+	ordStatus string
+
+	// set once a wait step has been satisfied by it
+	matched bool
+}
+
+// Script plays steps over the sessions of an initiator, checking the responses that come back
+type Script struct {
+	steps          []Step
+	sessions       []quickfix.SessionID
+	defaultTimeout time.Duration
+
+	mu        sync.Mutex
+	loggedOn  map[quickfix.SessionID]bool
+	responses []*response
+
+	// closed and replaced whenever a session logs on or a message arrives
+	changed chan struct{}
+}
+
+// NewScript returns a Script that runs steps over sessions, waiting at most timeout for each
+// response unless a step says otherwise
+func NewScript(steps []Step, sessions []quickfix.SessionID, timeout time.Duration) *Script {
+	return &Script{
+		steps:          steps,
+		sessions:       sessions,
+		defaultTimeout: timeout,
+		loggedOn:       make(map[quickfix.SessionID]bool),
+		changed:        make(chan struct{}),
+	}
+}
+
+func (s *Script) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// OnLogon records that sessionID can now be sent to
+func (s *Script) OnLogon(sessionID quickfix.SessionID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loggedOn[sessionID] = true
+	s.notify()
+}
+
+// OnLogout records that sessionID can no longer be sent to
+func (s *Script) OnLogout(sessionID quickfix.SessionID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loggedOn, sessionID)
+	s.notify()
+}
+
+// OnMessage records an application message for the wait steps to check
+func (s *Script) OnMessage(msg *quickfix.Message) {
+	resp := new(response)
+	resp.msgType, _ = msg.Header.GetString(tag.MsgType)
+	resp.clOrdID, _ = msg.Body.GetString(tag.ClOrdID)
+	resp.mdReqID, _ = msg.Body.GetString(tag.MDReqID)
+	resp.ordStatus, _ = msg.Body.GetString(tag.OrdStatus)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, resp)
+	s.notify()
+}
+
+// await blocks until done returns true, checked under the lock each time something changes,
+// and fails after timeout
+func (s *Script) await(timeout time.Duration, done func() bool) bool {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		ok := done()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if ok {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// Run plays every step in turn, returning an error for the first step that fails
+func (s *Script) Run() error {
+	var sessionID quickfix.SessionID
+	for i, step := range s.steps {
+		timeout := s.defaultTimeout
+		if step.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(step.Timeout); err != nil {
+				return fmt.Errorf("step %v: invalid timeout %v", i+1, step.Timeout)
+			}
+		}
+
+		if step.Action != ActionSleep && step.Action != ActionWait {
+			var err error
+			if sessionID, err = s.session(step.BeginString, sessionID); err != nil {
+				return fmt.Errorf("step %v: %s", i+1, err)
+			}
+
+			if !s.await(timeout, func() bool { return s.loggedOn[sessionID] }) {
+				return fmt.Errorf("step %v: %v did not log on within %v", i+1, sessionID, timeout)
+			}
+		}
+
+		var err error
+		switch step.Action {
+		case ActionOrder:
+			err = quickfix.SendToTarget(newOrderSingle(step, sessionID.BeginString), sessionID)
+		case ActionCancel:
+			err = quickfix.SendToTarget(newOrderCancelRequest(step, sessionID.BeginString), sessionID)
+		case ActionReplace:
+			err = quickfix.SendToTarget(newOrderCancelReplaceRequest(step, sessionID.BeginString), sessionID)
+		case ActionMarketData:
+			err = quickfix.SendToTarget(newMarketDataRequest(step), sessionID)
+		case ActionWait:
+			err = s.wait(step, timeout)
+		case ActionSleep:
+			time.Sleep(timeout)
+		default:
+			err = fmt.Errorf("unknown action %q", step.Action)
+		}
+
+		if err != nil {
+			utils.PrintBad(fmt.Sprintf("step %v %v: %s", i+1, step.Action, err))
+			return fmt.Errorf("step %v: %s", i+1, err)
+		}
+		utils.PrintInfo(fmt.Sprintf("step %v %v: ok", i+1, step.Action))
+	}
+
+	return nil
+}
+
+// session returns the session for beginString, current if beginString is empty
+func (s *Script) session(beginString string, current quickfix.SessionID) (quickfix.SessionID, error) {
+	if beginString == "" {
+		switch {
+		case current != quickfix.SessionID{}:
+			return current, nil
+		case len(s.sessions) == 1:
+			return s.sessions[0], nil
+		}
+
+		return current, fmt.Errorf("beginString is required, the config has %v sessions", len(s.sessions))
+	}
+
+	for _, sessionID := range s.sessions {
+		if sessionID.BeginString == beginString {
+			return sessionID, nil
+		}
+	}
+
+	return current, fmt.Errorf("no %v session in the config", beginString)
+}
+
+// wait blocks until a message matching step arrives that no earlier wait step has claimed
+func (s *Script) wait(step Step, timeout time.Duration) error {
+	msgType := step.MsgType
+	if msgType == "" {
+		msgType = string(enum.MsgType_EXECUTION_REPORT)
+		if step.ClOrdID == "" && step.MDReqID != "" {
+			msgType = string(enum.MsgType_MARKET_DATA_SNAPSHOT_FULL_REFRESH)
+		}
+	}
+
+	var ordStatus string
+	if step.OrdStatus != "" {
+		ordStatus = string(enumValue(ordStatuses, step.OrdStatus))
+	}
+
+	matches := func(resp *response) bool {
+		switch {
+		case resp.matched, resp.msgType != msgType:
+			return false
+		case step.ClOrdID != "" && resp.clOrdID != step.ClOrdID:
+			return false
+		case step.MDReqID != "" && resp.mdReqID != step.MDReqID:
+			return false
+		case ordStatus != "" && resp.ordStatus != ordStatus:
+			return false
+		}
+
+		return true
+	}
+
+	found := s.await(timeout, func() bool {
+		for _, resp := range s.responses {
+			if matches(resp) {
+				resp.matched = true
+				return true
+			}
+		}
+
+		return false
+	})
+
+	if !found {
+		return fmt.Errorf("no message 35=%v ClOrdID=%q MDReqID=%q OrdStatus=%q within %v", msgType, step.ClOrdID, step.MDReqID, ordStatus, timeout)
+	}
+
+	return nil
+}
+
+func newMessage(msgType enum.MsgType) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.Set(field.NewMsgType(msgType))
+
+	return msg
+}
+
+func newOrderSingle(step Step, beginString string) *quickfix.Message {
+	ordType := enumValue(ordTypes, step.OrdType)
+	if step.OrdType == "" {
+		ordType = enum.OrdType_LIMIT
+	}
+
+	msg := newMessage(enum.MsgType_ORDER_SINGLE)
+	msg.Body.Set(field.NewClOrdID(step.ClOrdID))
+	msg.Body.Set(field.NewHandlInst(enum.HandlInst_AUTOMATED_EXECUTION_ORDER_PRIVATE_NO_BROKER_INTERVENTION))
+	msg.Body.Set(field.NewSymbol(step.Symbol))
+	msg.Body.Set(field.NewSide(enumValue(sides, step.Side)))
+	msg.Body.Set(field.NewOrderQty(step.Quantity, 2))
+	msg.Body.Set(field.NewOrdType(ordType))
+	setPrices(msg, ordType, step)
+	if step.TimeInForce != "" {
+		msg.Body.Set(field.NewTimeInForce(enumValue(timesInForce, step.TimeInForce)))
+	}
+	setTransactTime(msg, beginString)
+
+	return msg
+}
+
+func newOrderCancelRequest(step Step, beginString string) *quickfix.Message {
+	msg := newMessage(enum.MsgType_ORDER_CANCEL_REQUEST)
+	msg.Body.Set(field.NewOrigClOrdID(step.OrigClOrdID))
+	msg.Body.Set(field.NewClOrdID(step.ClOrdID))
+	msg.Body.Set(field.NewSymbol(step.Symbol))
+	msg.Body.Set(field.NewSide(enumValue(sides, step.Side)))
+	msg.Body.Set(field.NewOrderQty(step.Quantity, 2))
+	if beginString == quickfix.BeginStringFIX40 {
+		msg.Body.Set(field.NewCxlType("F"))
+	}
+	setTransactTime(msg, beginString)
+
+	return msg
+}
+
+func newOrderCancelReplaceRequest(step Step, beginString string) *quickfix.Message {
+	ordType := enumValue(ordTypes, step.OrdType)
+	if step.OrdType == "" {
+		ordType = enum.OrdType_LIMIT
+	}
+
+	msg := newMessage(enum.MsgType_ORDER_CANCEL_REPLACE_REQUEST)
+	msg.Body.Set(field.NewOrigClOrdID(step.OrigClOrdID))
+	msg.Body.Set(field.NewClOrdID(step.ClOrdID))
+	msg.Body.Set(field.NewHandlInst(enum.HandlInst_AUTOMATED_EXECUTION_ORDER_PRIVATE_NO_BROKER_INTERVENTION))
+	msg.Body.Set(field.NewSymbol(step.Symbol))
+	msg.Body.Set(field.NewSide(enumValue(sides, step.Side)))
+	msg.Body.Set(field.NewOrderQty(step.Quantity, 2))
+	msg.Body.Set(field.NewOrdType(ordType))
+	setPrices(msg, ordType, step)
+	setTransactTime(msg, beginString)
+
+	return msg
+}
+
+// setPrices adds the limit and stop price ordType calls for
+func setPrices(msg *quickfix.Message, ordType enum.OrdType, step Step) {
+	switch ordType {
+	case enum.OrdType_LIMIT, enum.OrdType_STOP_LIMIT:
+		msg.Body.Set(field.NewPrice(step.Price, 2))
+	}
+
+	switch ordType {
+	case enum.OrdType_STOP, enum.OrdType_STOP_LIMIT:
+		msg.Body.Set(field.NewStopPx(step.StopPx, 2))
+	}
+}
+
+// setTransactTime adds TransactTime to messages of the versions that require it, FIX.4.2 on
+func setTransactTime(msg *quickfix.Message, beginString string) {
+	switch beginString {
+	case quickfix.BeginStringFIX40, quickfix.BeginStringFIX41:
+		return
+	}
+
+	msg.Body.Set(field.NewTransactTime(time.Now()))
+}
+
+func newMarketDataRequest(step Step) *quickfix.Message {
+	subscription := enum.SubscriptionRequestType_SNAPSHOT
+	if step.Subscription != "" {
+		subscription = enumValue(subscriptions, step.Subscription)
+	}
+
+	msg := newMessage(enum.MsgType_MARKET_DATA_REQUEST)
+	msg.Body.Set(field.NewMDReqID(step.MDReqID))
+	msg.Body.Set(field.NewSubscriptionRequestType(subscription))
+	msg.Body.Set(field.NewMarketDepth(0))
+
+	entryTypes := quickfix.NewRepeatingGroup(tag.NoMDEntryTypes, quickfix.GroupTemplate{quickfix.GroupElement(tag.MDEntryType)})
+	for _, entryType := range []enum.MDEntryType{enum.MDEntryType_BID, enum.MDEntryType_OFFER, enum.MDEntryType_TRADE} {
+		entryTypes.Add().Set(field.NewMDEntryType(entryType))
+	}
+	msg.Body.SetGroup(entryTypes)
+
+	relatedSym := quickfix.NewRepeatingGroup(tag.NoRelatedSym, quickfix.GroupTemplate{quickfix.GroupElement(tag.Symbol)})
+	relatedSym.Add().Set(field.NewSymbol(step.Symbol))
+	msg.Body.SetGroup(relatedSym)
+
+	return msg
+}
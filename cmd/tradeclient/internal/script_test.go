@@ -0,0 +1,176 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/tag"
+	"github.com/shopspring/decimal"
+)
+
+func TestLoadScript(t *testing.T) {
+	want := []Step{
+		{Action: ActionOrder, BeginString: quickfix.BeginStringFIX42, ClOrdID: "1", Symbol: "ABC", Side: "buy", OrdType: "limit", Price: decimal.RequireFromString("10.5"), Quantity: decimal.NewFromInt(100)},
+		{Action: ActionWait, MsgType: "8", ClOrdID: "1", OrdStatus: "new", Timeout: "5s"},
+	}
+
+	tests := []struct {
+		fileName string
+		contents string
+	}{
+		{
+			fileName: "script.yaml",
+			contents: `
+- {action: order, beginString: FIX.4.2, clOrdID: "1", symbol: ABC, side: buy, ordType: limit, price: 10.5, quantity: 100}
+- {action: wait, msgType: "8", clOrdID: "1", ordStatus: new, timeout: 5s}
+`,
+		},
+		{
+			fileName: "script.json",
+			contents: `[
+	{"action": "order", "beginString": "FIX.4.2", "clOrdID": "1", "symbol": "ABC", "side": "buy", "ordType": "limit", "price": 10.5, "quantity": 100},
+	{"action": "wait", "msgType": "8", "clOrdID": "1", "ordStatus": "new", "timeout": "5s"}
+]`,
+		},
+		{
+			fileName: "script.csv",
+			contents: `action, beginString, clOrdID, symbol, side, ordType, price, quantity, msgType, ordStatus, timeout
+# a comment line
+order, FIX.4.2, 1, ABC, buy, limit, 10.5, 100
+wait, , 1, , , , , , 8, new, 5s
+`,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		fileName := filepath.Join(dir, test.fileName)
+		if err := os.WriteFile(fileName, []byte(test.contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		steps, err := LoadScript(fileName)
+		if err != nil {
+			t.Errorf("%v: %s", test.fileName, err)
+			continue
+		}
+
+		if len(steps) != len(want) {
+			t.Errorf("%v: %v steps, want %v", test.fileName, len(steps), len(want))
+			continue
+		}
+		for i := range want {
+			got := steps[i]
+			if !got.Price.Equal(want[i].Price) || !got.Quantity.Equal(want[i].Quantity) {
+				t.Errorf("%v step %v: price %v quantity %v, want %v and %v", test.fileName, i, got.Price, got.Quantity, want[i].Price, want[i].Quantity)
+			}
+			got.Price, got.Quantity = want[i].Price, want[i].Quantity
+			if !reflect.DeepEqual(got, want[i]) {
+				t.Errorf("%v step %v: got %+v, want %+v", test.fileName, i, got, want[i])
+			}
+		}
+	}
+
+	fileName := filepath.Join(dir, "script.txt")
+	if err := os.WriteFile(fileName, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScript(fileName); err == nil {
+		t.Errorf("script.txt: loaded a script of an unknown format")
+	}
+}
+
+func TestEnumValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  enum.Side
+	}{
+		{"buy", enum.Side_BUY},
+		{"SELL", enum.Side_SELL},
+		{"sell_short", enum.Side_SELL_SHORT},
+		{"2", enum.Side_SELL},
+		{"9", enum.Side("9")},
+	}
+
+	for _, test := range tests {
+		if got := enumValue(sides, test.value); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestScriptMessages(t *testing.T) {
+	step := Step{
+		ClOrdID:     "2",
+		OrigClOrdID: "1",
+		Symbol:      "ABC",
+		Side:        "sell",
+		OrdType:     "stop_limit",
+		Price:       decimal.NewFromInt(10),
+		StopPx:      decimal.NewFromInt(11),
+		Quantity:    decimal.NewFromInt(100),
+	}
+
+	builders := map[string]func(Step, string) *quickfix.Message{
+		"order":   newOrderSingle,
+		"cancel":  newOrderCancelRequest,
+		"replace": newOrderCancelReplaceRequest,
+	}
+
+	tests := []struct {
+		message      string
+		beginString  string
+		transactTime bool
+		cxlType      bool
+		prices       bool
+	}{
+		{"order", quickfix.BeginStringFIX40, false, false, true},
+		{"order", quickfix.BeginStringFIX41, false, false, true},
+		{"order", quickfix.BeginStringFIX42, true, false, true},
+		{"order", quickfix.BeginStringFIX44, true, false, true},
+		{"cancel", quickfix.BeginStringFIX40, false, true, false},
+		{"cancel", quickfix.BeginStringFIX41, false, false, false},
+		{"cancel", quickfix.BeginStringFIX44, true, false, false},
+		{"replace", quickfix.BeginStringFIX41, false, false, true},
+		{"replace", quickfix.BeginStringFIX44, true, false, true},
+	}
+
+	for _, test := range tests {
+		msg := builders[test.message](step, test.beginString)
+		name := test.message + " " + test.beginString
+
+		if got := msg.Body.Has(tag.TransactTime); got != test.transactTime {
+			t.Errorf("%v: TransactTime set %v, want %v", name, got, test.transactTime)
+		}
+		if got := msg.Body.Has(tag.CxlType); got != test.cxlType {
+			t.Errorf("%v: CxlType set %v, want %v", name, got, test.cxlType)
+		}
+		if got := msg.Body.Has(tag.Price) && msg.Body.Has(tag.StopPx); got != test.prices {
+			t.Errorf("%v: Price and StopPx set %v, want %v", name, got, test.prices)
+		}
+
+		side, err := msg.Body.GetString(tag.Side)
+		if err != nil || enum.Side(side) != enum.Side_SELL {
+			t.Errorf("%v: Side %v, want %v", name, side, enum.Side_SELL)
+		}
+	}
+}
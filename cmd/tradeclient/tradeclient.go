@@ -21,6 +21,8 @@ import (
 	"io"
 	"os"
 	"path"
+	"sort"
+	"time"
 
 	"github.com/quickfixgo/examples/cmd/tradeclient/internal"
 	"github.com/quickfixgo/examples/cmd/utils"
@@ -32,16 +34,28 @@ import (
 
 // TradeClient implements the quickfix.Application interface
 type TradeClient struct {
+	blotter *internal.Blotter
+
+	// script is nil unless the client is playing a script
+	script *internal.Script
 }
 
 // OnCreate implemented as part of Application interface
 func (e TradeClient) OnCreate(_ quickfix.SessionID) {}
 
 // OnLogon implemented as part of Application interface
-func (e TradeClient) OnLogon(_ quickfix.SessionID) {}
+func (e TradeClient) OnLogon(sessionID quickfix.SessionID) {
+	if e.script != nil {
+		e.script.OnLogon(sessionID)
+	}
+}
 
 // OnLogout implemented as part of Application interface
-func (e TradeClient) OnLogout(_ quickfix.SessionID) {}
+func (e TradeClient) OnLogout(sessionID quickfix.SessionID) {
+	if e.script != nil {
+		e.script.OnLogout(sessionID)
+	}
+}
 
 // FromAdmin implemented as part of Application interface
 func (e TradeClient) FromAdmin(_ *quickfix.Message, _ quickfix.SessionID) (reject quickfix.MessageRejectError) {
@@ -52,14 +66,19 @@ func (e TradeClient) FromAdmin(_ *quickfix.Message, _ quickfix.SessionID) (rejec
 func (e TradeClient) ToAdmin(_ *quickfix.Message, _ quickfix.SessionID) {}
 
 // ToApp implemented as part of Application interface
-func (e TradeClient) ToApp(msg *quickfix.Message, _ quickfix.SessionID) (err error) {
+func (e TradeClient) ToApp(msg *quickfix.Message, sessionID quickfix.SessionID) (err error) {
 	utils.PrintInfo(fmt.Sprintf("Sending: %s", msg.String()))
+	e.blotter.OnSend(msg, sessionID)
 	return
 }
 
 // FromApp implemented as part of Application interface. This is the callback for all Application level messages from the counter party.
 func (e TradeClient) FromApp(msg *quickfix.Message, _ quickfix.SessionID) (reject quickfix.MessageRejectError) {
 	utils.PrintInfo(fmt.Sprintf("FromApp: %s", msg.String()))
+	e.blotter.OnReceive(msg)
+	if e.script != nil {
+		e.script.OnMessage(msg)
+	}
 	return
 }
 
@@ -79,8 +98,23 @@ var (
 		Example: "qf tradeclient [YOUR_FIX_CONFIG_FILE_HERE.cfg] (default is ./config/tradeclient.cfg)",
 		RunE:    execute,
 	}
+
+	// storeF overrides the message store picked by the config file.
+	storeF string
+
+	// scriptF names a script to play instead of prompting on the console.
+	scriptF string
+
+	// timeoutF bounds how long a script waits for each response.
+	timeoutF time.Duration
 )
 
+func init() {
+	Cmd.Flags().StringVar(&storeF, "store", "", "message store to use: memory, file or sql (default picked from the config file)")
+	Cmd.Flags().StringVar(&scriptF, "script", "", "play the orders, cancels, market data requests and waits of a YAML, JSON or CSV script, then exit")
+	Cmd.Flags().DurationVar(&timeoutF, "timeout", 10*time.Second, "how long a script waits for a session to log on or a response to arrive")
+}
+
 func execute(_ *cobra.Command, args []string) error {
 	var cfgFileName string
 	argLen := len(args)
@@ -117,14 +151,34 @@ func execute(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("error reading cfg: %s,", err)
 	}
 
-	app := TradeClient{}
+	app := TradeClient{blotter: internal.NewBlotter()}
+	if scriptF != "" {
+		steps, err := internal.LoadScript(scriptF)
+		if err != nil {
+			return err
+		}
+
+		var sessions []quickfix.SessionID
+		for sessionID := range appSettings.SessionSettings() {
+			sessions = append(sessions, sessionID)
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].String() < sessions[j].String() })
+
+		app.script = internal.NewScript(steps, sessions, timeoutF)
+	}
+
 	fileLogFactory, err := file.NewLogFactory(appSettings)
 
 	if err != nil {
 		return fmt.Errorf("error creating file log factory: %s,", err)
 	}
 
-	initiator, err := quickfix.NewInitiator(app, quickfix.NewMemoryStoreFactory(), appSettings, fileLogFactory)
+	storeFactory, err := utils.NewStoreFactory(appSettings, storeF)
+	if err != nil {
+		return fmt.Errorf("unable to create message store: %s", err)
+	}
+
+	initiator, err := quickfix.NewInitiator(app, storeFactory, appSettings, fileLogFactory)
 	if err != nil {
 		return fmt.Errorf("unable to create initiator: %s", err)
 	}
@@ -136,6 +190,15 @@ func execute(_ *cobra.Command, args []string) error {
 
 	utils.PrintConfig("initiator", bytes.NewReader(stringData))
 
+	if app.script != nil {
+		err = app.script.Run()
+
+		utils.PrintInfo("stopping FIX initiator ..")
+		initiator.Stop()
+		utils.PrintInfo("stopped")
+		return err
+	}
+
 Loop:
 	for {
 		action, err := internal.QueryAction()
@@ -154,6 +217,15 @@ Loop:
 			err = internal.QueryMarketDataRequest()
 
 		case "4":
+			internal.QueryListOrders(app.blotter)
+
+		case "5":
+			err = internal.QueryCancelOpenOrder(app.blotter)
+
+		case "6":
+			err = internal.QueryReplaceOpenOrder(app.blotter)
+
+		case "7":
 			//quit
 			break Loop
 
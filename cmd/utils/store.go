@@ -0,0 +1,164 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/quickfix/config"
+	"github.com/quickfixgo/quickfix/store/file"
+	sqlstore "github.com/quickfixgo/quickfix/store/sql"
+
+	// registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// The message stores a command can run with
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+	StoreSQL    = "sql"
+)
+
+// StoreTypeSetting names the [DEFAULT] setting that picks the message store
+const StoreTypeSetting = "StoreType"
+
+const sqliteDriver = "sqlite"
+
+// sqliteSchema creates the tables the quickfix SQL store reads and writes
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS sessions (
+		beginstring CHAR(8) NOT NULL,
+		sendercompid VARCHAR(64) NOT NULL,
+		sendersubid VARCHAR(64) NOT NULL,
+		senderlocid VARCHAR(64) NOT NULL,
+		targetcompid VARCHAR(64) NOT NULL,
+		targetsubid VARCHAR(64) NOT NULL,
+		targetlocid VARCHAR(64) NOT NULL,
+		session_qualifier VARCHAR(64) NOT NULL,
+		creation_time DATETIME NOT NULL,
+		incoming_seqnum INT NOT NULL,
+		outgoing_seqnum INT NOT NULL,
+		PRIMARY KEY (beginstring, sendercompid, sendersubid, senderlocid,
+			targetcompid, targetsubid, targetlocid, session_qualifier)
+	)`,
+	`CREATE TABLE IF NOT EXISTS messages (
+		beginstring CHAR(8) NOT NULL,
+		sendercompid VARCHAR(64) NOT NULL,
+		sendersubid VARCHAR(64) NOT NULL,
+		senderlocid VARCHAR(64) NOT NULL,
+		targetcompid VARCHAR(64) NOT NULL,
+		targetsubid VARCHAR(64) NOT NULL,
+		targetlocid VARCHAR(64) NOT NULL,
+		session_qualifier VARCHAR(64) NOT NULL,
+		msgseqnum INT NOT NULL,
+		message TEXT NOT NULL,
+		PRIMARY KEY (beginstring, sendercompid, sendersubid, senderlocid,
+			targetcompid, targetsubid, targetlocid, session_qualifier,
+			msgseqnum)
+	)`,
+}
+
+// NewStoreFactory returns the message store factory for settings. storeType picks the store
+// when it is not empty, then the StoreType setting, then whichever of FileStorePath or
+// SQLStoreDriver is configured, falling back to a memory store.
+func NewStoreFactory(settings *quickfix.Settings, storeType string) (quickfix.MessageStoreFactory, error) {
+	global := settings.GlobalSettings()
+
+	if storeType == "" && global.HasSetting(StoreTypeSetting) {
+		storeType, _ = global.Setting(StoreTypeSetting)
+	}
+
+	if storeType == "" {
+		switch {
+		case global.HasSetting(config.FileStorePath):
+			storeType = StoreFile
+		case global.HasSetting(config.SQLStoreDriver):
+			storeType = StoreSQL
+		default:
+			storeType = StoreMemory
+		}
+	}
+
+	switch strings.ToLower(storeType) {
+	case StoreMemory:
+		return quickfix.NewMemoryStoreFactory(), nil
+
+	case StoreFile:
+		if !global.HasSetting(config.FileStorePath) {
+			return nil, fmt.Errorf("file store requires the %v setting", config.FileStorePath)
+		}
+		return file.NewStoreFactory(settings), nil
+
+	case StoreSQL:
+		if !global.HasSetting(config.SQLStoreDriver) || !global.HasSetting(config.SQLStoreDataSourceName) {
+			return nil, fmt.Errorf("sql store requires the %v and %v settings", config.SQLStoreDriver, config.SQLStoreDataSourceName)
+		}
+
+		driver, _ := global.Setting(config.SQLStoreDriver)
+		dataSourceName, _ := global.Setting(config.SQLStoreDataSourceName)
+		if driver == sqliteDriver {
+			// every session opens its own connection to the database file, so writers have
+			// to wait on each other's locks rather than fail
+			if !strings.Contains(dataSourceName, "busy_timeout") {
+				dataSourceName += sqliteParamSeparator(dataSourceName) + "_pragma=busy_timeout(5000)"
+				global.Set(config.SQLStoreDataSourceName, dataSourceName)
+			}
+
+			if err := createSQLiteSchema(dataSourceName); err != nil {
+				return nil, fmt.Errorf("unable to create sqlite store %v: %s", dataSourceName, err)
+			}
+		}
+		return sqlstore.NewStoreFactory(settings), nil
+	}
+
+	return nil, fmt.Errorf("unknown message store %q, expected %v, %v or %v", storeType, StoreMemory, StoreFile, StoreSQL)
+}
+
+func sqliteParamSeparator(dataSourceName string) string {
+	if strings.Contains(dataSourceName, "?") {
+		return "&"
+	}
+
+	return "?"
+}
+
+// createSQLiteSchema creates the local database and its tables if they do not exist yet
+func createSQLiteSchema(dataSourceName string) error {
+	path := strings.TrimPrefix(strings.SplitN(dataSourceName, "?", 2)[0], "file:")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	db, err := sql.Open(sqliteDriver, dataSourceName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, statement := range sqliteSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
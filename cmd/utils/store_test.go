@@ -0,0 +1,97 @@
+// Copyright (c) quickfixengine.org  All rights reserved.
+//
+// This file may be distributed under the terms of the quickfixengine.org
+// license as defined by quickfixengine.org and appearing in the file
+// LICENSE included in the packaging of this file.
+//
+// This file is provided AS IS with NO WARRANTY OF ANY KIND, INCLUDING
+// THE WARRANTY OF DESIGN, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE.
+//
+// See http://www.quickfixengine.org/LICENSE for licensing information.
+//
+// Contact ask@quickfixengine.org if any conditions of this licensing
+// are not clear to you.
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/quickfixgo/quickfix"
+	"github.com/quickfixgo/quickfix/config"
+)
+
+// testSession is the session section ParseSettings requires
+const testSession = "[SESSION]\nBeginString=FIX.4.4\nSenderCompID=TW\nTargetCompID=ISLD\n"
+
+func TestNewStoreFactory(t *testing.T) {
+	dir := t.TempDir()
+	fileStore := fmt.Sprintf("%v=%v\n", config.FileStorePath, filepath.Join(dir, "store"))
+	sqlStore := fmt.Sprintf("%v=sqlite\n%v=%v\n", config.SQLStoreDriver, config.SQLStoreDataSourceName, filepath.Join(dir, "db", "store.db"))
+
+	tests := []struct {
+		name      string
+		settings  string
+		storeType string
+		want      string
+		wantErr   bool
+	}{
+		{name: "no settings", want: StoreMemory},
+		{name: "file store path", settings: fileStore, want: StoreFile},
+		{name: "sql store driver", settings: sqlStore, want: StoreSQL},
+		{name: "setting over path", settings: fileStore + StoreTypeSetting + "=memory\n", want: StoreMemory},
+		{name: "flag over setting", settings: fileStore + StoreTypeSetting + "=memory\n", storeType: "FILE", want: StoreFile},
+		{name: "file store without path", storeType: StoreFile, wantErr: true},
+		{name: "sql store without driver", storeType: StoreSQL, wantErr: true},
+		{name: "unknown store", storeType: "mongo", wantErr: true},
+	}
+
+	for _, test := range tests {
+		settings, err := quickfix.ParseSettings(strings.NewReader("[DEFAULT]\n" + test.settings + testSession))
+		if err != nil {
+			t.Fatalf("%v: %s", test.name, err)
+		}
+
+		factory, err := NewStoreFactory(settings, test.storeType)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: got a %T, want an error", test.name, factory)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %s", test.name, err)
+			continue
+		}
+
+		if got := fmt.Sprintf("%T", factory); !strings.Contains(strings.ToLower(got), test.want) {
+			t.Errorf("%v: got a %v, want a %v store", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewStoreFactorySQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db", "store.db")
+	settings, err := quickfix.ParseSettings(strings.NewReader(fmt.Sprintf("[DEFAULT]\n%v=sqlite\n%v=%v\n"+testSession, config.SQLStoreDriver, config.SQLStoreDataSourceName, path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStoreFactory(settings, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database not created: %s", err)
+	}
+
+	dataSourceName, _ := settings.GlobalSettings().Setting(config.SQLStoreDataSourceName)
+	if want := path + "?_pragma=busy_timeout(5000)"; dataSourceName != want {
+		t.Errorf("got data source %v, want %v", dataSourceName, want)
+	}
+}
@@ -24,6 +24,6 @@ import (
 func main() {
 	err := cmd.Execute()
 	if err != nil {
-		os.Exit(0)
+		os.Exit(1)
 	}
 }